@@ -0,0 +1,35 @@
+package ctx
+
+// Call is a request to run a server handler, made with Context.Call.
+type Call struct {
+	Name  string // Handler ID registered on the server with On
+	Value string // Passed to the handler as InputValue
+}
+
+// Call asks the server to run the handler registered under name, with
+// value as its InputValue. It is how a client island talks to the
+// server: the call is sent after the island's handler returns, and the
+// page re-renders as for any other event. On the server it runs the
+// handler directly, after the current one.
+//
+//	// In the island:
+//	ui.Button(ui.T("Save")).OnClick(c, func(c *ctx.Context) {
+//	    c.Call("save-draft", c.String("text"))
+//	})
+//
+//	// In the page:
+//	c.On("save-draft", func(c *ctx.Context) { saveDraft(c.InputValue()) })
+func (c *Context) Call(name, value string) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Name: name, Value: value})
+	c.mu.Unlock()
+}
+
+// TakeCalls returns and clears the calls made since the last call.
+func (c *Context) TakeCalls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
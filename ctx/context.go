@@ -1,6 +1,9 @@
 package ctx
 
-import "sync"
+import (
+	"net/url"
+	"sync"
+)
 
 // EventHandler is a function that handles UI events.
 // It receives the Context to read/write state.
@@ -33,11 +36,25 @@ type EventHandler func(*Context)
 //
 //	// For route "/user/:id"
 //	userID := c.Params["id"]
+//
+// # Query String
+//
+//	term := c.Query("q")
+//	c.SyncURL("page", "q")  // mirror state into the URL
 type Context struct {
 	mu         sync.RWMutex
 	state      map[string]any
 	persistent map[string]bool
 	events     map[string]EventHandler
+	uploads    map[string]UploadHandler
+	downloads  []Download
+	calls      []Call
+	query      url.Values
+	synced     map[string]bool // Keys mirrored into the URL by SyncURL
+	urls       URLFunc
+	assets     AssetFunc
+	eventScope string            // Prefix of generated event IDs; see EventScope
+	eventSeq   uint64            // Number of the last event ID generated in eventScope
 	Params     map[string]string // Route parameters (e.g., :id)
 }
 
@@ -47,6 +64,7 @@ func New() *Context {
 		state:      make(map[string]any),
 		persistent: make(map[string]bool),
 		events:     make(map[string]EventHandler),
+		uploads:    make(map[string]UploadHandler),
 		Params:     make(map[string]string),
 	}
 }
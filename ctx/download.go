@@ -0,0 +1,40 @@
+package ctx
+
+import (
+	"bytes"
+	"io"
+)
+
+// Download is a file handed to the browser by a handler.
+type Download struct {
+	Filename    string
+	ContentType string
+	Content     io.Reader
+}
+
+// Download sends a file to the browser. The content is read once, when
+// the browser fetches it. If content implements io.Closer it is closed
+// afterwards, or when the download expires unfetched.
+//
+//	ui.Button(ui.T("Export CSV")).OnClick(c, func(c *ctx.Context) {
+//	    c.Download("report.csv", "text/csv", bytes.NewReader(buildCSV()))
+//	})
+func (c *Context) Download(filename, contentType string, content io.Reader) {
+	c.mu.Lock()
+	c.downloads = append(c.downloads, Download{Filename: filename, ContentType: contentType, Content: content})
+	c.mu.Unlock()
+}
+
+// DownloadBytes sends in-memory data to the browser as a file.
+func (c *Context) DownloadBytes(filename, contentType string, data []byte) {
+	c.Download(filename, contentType, bytes.NewReader(data))
+}
+
+// TakeDownloads returns and clears the downloads requested since the last call.
+func (c *Context) TakeDownloads() []Download {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.downloads
+	c.downloads = nil
+	return d
+}
@@ -0,0 +1,110 @@
+package ctx
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// SetQuery sets the query string of the page's URL. The server calls it
+// for the HTTP render and when a session connects.
+func (c *Context) SetQuery(q url.Values) {
+	c.mu.Lock()
+	c.query = q
+	c.mu.Unlock()
+}
+
+// Query returns the first value of key in the page's query string, or ""
+// if it is not present. Keys mirrored with SyncURL follow their state.
+//
+//	// /search?q=forge
+//	term := c.Query("q") // "forge"
+func (c *Context) Query(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.query.Get(key)
+}
+
+// SyncURL mirrors state keys into the browser URL's query string, so the
+// page can be bookmarked, shared and reloaded in the same state. Call it
+// from the page function. The first call for a key restores its value
+// from the query string: with the type of the key's current value if it
+// has one, otherwise as an int if the value is a whole number and as a
+// string if not. After every re-render the URL is updated with
+// history.replaceState; keys that are unset or "" are removed.
+//
+//	func Products(c *forge.Context) ui.UI {
+//	    c.SyncURL("products_page", "sort", "q")
+//	    ...
+//	    ui.Pagination("products", c, total, 20)
+//	}
+func (c *Context) SyncURL(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.synced == nil {
+		c.synced = make(map[string]bool)
+	}
+	for _, key := range keys {
+		if c.synced[key] {
+			continue
+		}
+		c.synced[key] = true
+		if v, ok := c.query[key]; ok && len(v) > 0 {
+			c.state[key] = parseQueryValue(v[0], c.state[key])
+		}
+	}
+}
+
+// parseQueryValue converts a query value to the type of current.
+func parseQueryValue(s string, current any) any {
+	switch current.(type) {
+	case string:
+		return s
+	case bool:
+		b, _ := strconv.ParseBool(s)
+		return b
+	case float64:
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	case int, nil:
+		if n, err := strconv.Atoi(s); err == nil {
+			return n
+		}
+		if current != nil {
+			return 0
+		}
+	}
+	return s
+}
+
+// TakeURL returns the query string (with its leading "?", or "" when
+// empty) that the synced keys call for, and whether it differs from the
+// current one. A change becomes the current query string.
+func (c *Context) TakeURL() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.synced) == 0 {
+		return "", false
+	}
+	q := url.Values{}
+	for k, v := range c.query {
+		q[k] = v
+	}
+	for key := range c.synced {
+		switch v := c.state[key]; v {
+		case nil, "":
+			q.Del(key)
+		default:
+			q.Set(key, fmt.Sprint(v))
+		}
+	}
+	encoded, old := q.Encode(), c.query.Encode()
+	if encoded == old {
+		return "", false
+	}
+	c.query = q
+	if encoded == "" {
+		return "", true
+	}
+	return "?" + encoded, true
+}
@@ -0,0 +1,17 @@
+package ctx
+
+import "slices"
+
+// SetRoles replaces the roles of the session's user, which guards such
+// as server.RequireRole check. The server sets them from the request
+// (see server.WithRoles); handlers may change them, e.g. on logout.
+func (c *Context) SetRoles(roles ...string) { c.Set("_roles", roles) }
+
+// Roles returns the roles of the session's user.
+func (c *Context) Roles() []string {
+	roles, _ := c.Get("_roles").([]string)
+	return roles
+}
+
+// HasRole reports whether the session's user has role.
+func (c *Context) HasRole(role string) bool { return slices.Contains(c.Roles(), role) }
@@ -0,0 +1,41 @@
+package ctx
+
+// EventScope returns the prefix of the event IDs being generated for this
+// Context, "" outside a Memo or client island. The ui package sets it
+// with SetEventScope while rendering them.
+func (c *Context) EventScope() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.eventScope
+}
+
+// SetEventScope sets the prefix of generated event IDs and numbers them
+// from 1 again. It returns a function that restores the previous prefix
+// and numbering.
+func (c *Context) SetEventScope(scope string) (restore func()) {
+	c.mu.Lock()
+	prevScope, prevSeq := c.eventScope, c.eventSeq
+	c.eventScope, c.eventSeq = scope, 0
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		c.eventScope, c.eventSeq = prevScope, prevSeq
+		c.mu.Unlock()
+	}
+}
+
+// NextEventSeq returns the number of the next event ID generated in the
+// current scope, counting from 1.
+func (c *Context) NextEventSeq() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.eventSeq++
+	return c.eventSeq
+}
+
+// ResetEventSeq numbers the event IDs of the next render from 1 again.
+func (c *Context) ResetEventSeq() {
+	c.mu.Lock()
+	c.eventSeq = 0
+	c.mu.Unlock()
+}
@@ -0,0 +1,48 @@
+package ctx
+
+// UploadedFile is a file streamed from the browser over the session socket.
+type UploadedFile struct {
+	Name        string // Base name reported by the browser, sanitized by the server
+	ContentType string // MIME type sniffed from the content
+	Size        int64  // Size in bytes
+	Data        []byte // File contents
+}
+
+// UploadHandler handles a completed upload inside the session.
+// It has the same access to state as an EventHandler.
+//
+//	func handleAvatar(c *ctx.Context, f ctx.UploadedFile) {
+//	    c.Set("avatar", f.Name)
+//	}
+type UploadHandler func(*Context, UploadedFile)
+
+// OnUpload registers an upload handler with the given ID.
+func (c *Context) OnUpload(id string, handler UploadHandler) {
+	c.mu.Lock()
+	c.uploads[id] = handler
+	c.mu.Unlock()
+}
+
+// HandleUpload executes the upload handler with the given ID.
+// Returns true if the handler was found and executed.
+func (c *Context) HandleUpload(id string, file UploadedFile) bool {
+	c.mu.RLock()
+	handler, ok := c.uploads[id]
+	c.mu.RUnlock()
+	if ok {
+		handler(c, file)
+	}
+	return ok
+}
+
+// HasUploadHandler reports whether an upload handler is registered
+// with the given ID.
+func (c *Context) HasUploadHandler(id string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.uploads[id] != nil
+}
+
+// UploadProgress returns the progress (0-100) of the upload bound to
+// the given handler ID.
+func (c *Context) UploadProgress(id string) int { return c.Int(id + "_progress") }
@@ -0,0 +1,54 @@
+package ctx
+
+// URLFunc builds the URL of a named route from key-value params.
+type URLFunc func(name string, params ...string) string
+
+// SetURLFunc sets the function URL uses. The server sets it to the
+// app's App.URL for every page.
+func (c *Context) SetURLFunc(fn URLFunc) {
+	c.mu.Lock()
+	c.urls = fn
+	c.mu.Unlock()
+}
+
+// URL builds the path of the route named name, with params given as
+// key-value pairs. An unknown name or invalid parameters give "#" and are
+// logged by the server.
+//
+//	// app.Route("/user/:id", UserPage, forge.Name("user"))
+//	ui.A(ui.T("Profile")).WithAttr("href", c.URL("user", "id", id))
+func (c *Context) URL(name string, params ...string) string {
+	c.mu.RLock()
+	fn := c.urls
+	c.mu.RUnlock()
+	if fn == nil {
+		return "#"
+	}
+	return fn(name, params...)
+}
+
+// AssetFunc returns the URL of a static file.
+type AssetFunc func(name string) string
+
+// SetAssetFunc sets the function Asset uses. The server sets it to the
+// app's App.Asset for every page.
+func (c *Context) SetAssetFunc(fn AssetFunc) {
+	c.mu.Lock()
+	c.assets = fn
+	c.mu.Unlock()
+}
+
+// Asset returns the fingerprinted URL of a file served with App.Static,
+// such as "/assets/logo.3f9a1b2c4d.png" for "logo.png". Without a
+// server it returns name.
+//
+//	ui.Img().WithAttr("src", c.Asset("logo.png"))
+func (c *Context) Asset(name string) string {
+	c.mu.RLock()
+	fn := c.assets
+	c.mu.RUnlock()
+	if fn == nil {
+		return name
+	}
+	return fn(name)
+}
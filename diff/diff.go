@@ -3,7 +3,9 @@
 package diff
 
 import (
-	"strconv"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/Shravanthh/forge/render"
 	"github.com/Shravanthh/forge/ui"
@@ -16,17 +18,21 @@ const (
 	Replace    PatchType = "replace" // Replace entire element
 	UpdateAttr PatchType = "attrs"   // Update attributes only
 	UpdateText PatchType = "text"    // Update text content
-	Insert     PatchType = "insert"  // Insert new element
+	Insert     PatchType = "insert"  // Insert new element into Parent, before Before
 	Remove     PatchType = "remove"  // Remove element
+	Move       PatchType = "move"    // Move existing element within Parent, before Before
 )
 
 // Patch represents a single DOM modification to be applied by the client.
 type Patch struct {
-	Type  PatchType         `json:"type"`            // Type of patch
-	ID    string            `json:"id"`              // Target element ID
-	HTML  string            `json:"html,omitempty"`  // New HTML (for replace/insert)
-	Attrs map[string]string `json:"attrs,omitempty"` // Changed attributes
-	Text  string            `json:"text,omitempty"`  // New text content
+	Type   PatchType         `json:"type"`             // Type of patch
+	ID     string            `json:"id"`               // Target element ID
+	Parent string            `json:"parent,omitempty"` // Parent element ID (for insert/move)
+	Before string            `json:"before,omitempty"` // Sibling to insert before; empty appends (for insert/move)
+	HTML   string            `json:"html,omitempty"`   // New HTML (for replace/insert)
+	Attrs  map[string]string `json:"attrs,omitempty"`  // Attributes to set
+	Unset  []string          `json:"unset,omitempty"`  // Attributes to remove
+	Text   string            `json:"text,omitempty"`   // New text content
 }
 
 // Diff compares two UI trees and returns the minimal set of patches
@@ -40,22 +46,30 @@ func Diff(oldUI, newUI ui.UI) []Patch {
 	return diffNode(oldUI, newUI, "0")
 }
 
+// DiffAt compares two subtrees rendered at path, as render.HTMLAt
+// renders them. The WASM client uses it to patch client islands.
+func DiffAt(oldUI, newUI ui.UI, path string) []Patch {
+	return diffNode(oldUI, newUI, path)
+}
+
 func diffNode(oldN, newN ui.UI, path string) []Patch {
+	if o, ok := oldN.(ui.Memoized); ok {
+		if n, ok := newN.(ui.Memoized); ok && o.Key == n.Key && o.Gen == n.Gen {
+			return nil
+		}
+	}
+	oldN, newN = ui.Unwrap(oldN), ui.Unwrap(newN)
 	if oldN == nil && newN == nil {
 		return nil
 	}
 	if oldN == nil {
-		return []Patch{{Type: Insert, ID: path, HTML: render.HTML(newN)}}
+		return []Patch{{Type: Insert, ID: path, HTML: render.HTMLAt(newN, path)}}
 	}
 	if newN == nil {
-		id := path
-		if e, ok := oldN.(ui.Element); ok && e.ID != "" {
-			id = e.ID
-		}
-		return []Patch{{Type: Remove, ID: id}}
+		return []Patch{{Type: Remove, ID: domID(oldN, path)}}
 	}
 	if nodeType(oldN) != nodeType(newN) {
-		return []Patch{{Type: Replace, ID: path, HTML: render.HTML(newN)}}
+		return []Patch{{Type: Replace, ID: domID(oldN, path), HTML: render.HTMLAt(newN, path)}}
 	}
 
 	switch o := oldN.(type) {
@@ -69,99 +83,229 @@ func diffNode(oldN, newN ui.UI, path string) []Patch {
 		if n := newN.(ui.Raw); o.HTML != n.HTML {
 			return []Patch{{Type: Replace, ID: path, HTML: n.HTML}}
 		}
+	case ui.FragmentNode:
+		// Only a root fragment gets here; nested ones are flattened.
+		return diffChildren(o.Children, newN.(ui.FragmentNode).Children, path)
+	case ui.IslandNode:
+		// The client owns an island's DOM; only a new island replaces it.
+		if n := newN.(ui.IslandNode); o.Name != n.Name || !maps.Equal(o.Props, n.Props) {
+			return []Patch{{Type: Replace, ID: domID(o, path), HTML: render.HTMLAt(n, path)}}
+		}
 	}
 	return nil
 }
 
 func diffElement(old, new ui.Element, path string) []Patch {
-	id := path
-	if new.ID != "" {
-		id = new.ID
-	}
+	id := domID(old, path)
 
-	if old.Tag != new.Tag {
-		return []Patch{{Type: Replace, ID: id, HTML: render.HTML(new)}}
+	// A namespace change needs a new DOM node. Text-only elements address
+	// their text through the element itself, other text is marked with
+	// comments, so switching between the two also re-renders the element.
+	// Raw HTML has no ID to patch, so an element whose raw children change
+	// is re-rendered too.
+	if old.Tag != new.Tag || old.NS != new.NS || render.TextOnly(old) != render.TextOnly(new) ||
+		rawChanged(old.Children, new.Children) {
+		return []Patch{{Type: Replace, ID: id, HTML: render.HTMLAt(new, path)}}
 	}
 
 	var patches []Patch
-	if attrs := diffAttrs(old, new); len(attrs) > 0 {
-		patches = append(patches, Patch{Type: UpdateAttr, ID: id, Attrs: attrs})
+	if attrs, unset := diffAttrs(old, new); len(attrs) > 0 || len(unset) > 0 {
+		patches = append(patches, Patch{Type: UpdateAttr, ID: id, Attrs: attrs, Unset: unset})
 	}
-	patches = append(patches, diffChildren(old.Children, new.Children, path)...)
+	patches = append(patches, diffChildren(old.Children, new.Children, id)...)
 	return patches
 }
 
-func diffAttrs(old, new ui.Element) map[string]string {
-	changes := make(map[string]string)
-	if old.Class != new.Class {
-		changes["class"] = new.Class
-	}
-	if old.Style != new.Style {
-		changes["style"] = new.Style
+// rawChanged reports whether the raw HTML children of two child lists
+// differ in content or position.
+func rawChanged(oldC, newC []ui.UI) bool {
+	raw := func(children []ui.UI) []render.Child {
+		var out []render.Child
+		for _, c := range render.Children("", children) {
+			if r, ok := ui.Unwrap(c.Node).(ui.Raw); ok {
+				out = append(out, render.Child{Node: r, Path: c.Path})
+			}
+		}
+		return out
 	}
-	for k, v := range new.Attrs {
-		if old.Attrs[k] != v {
-			changes[k] = v
+	return !slices.Equal(raw(oldC), raw(newC))
+}
+
+// diffAttrs compares attributes as they are rendered (see
+// render.AttrValue), so a boolean attribute switched off or an attribute
+// dropped by the renderer is removed rather than set to a value.
+func diffAttrs(old, new ui.Element) (set map[string]string, unset []string) {
+	set = make(map[string]string)
+	oldA, newA := renderedAttrs(old), renderedAttrs(new)
+	for k, v := range newA {
+		if ov, ok := oldA[k]; !ok || ov != v {
+			set[k] = v
 		}
 	}
-	for k := range old.Attrs {
-		if _, ok := new.Attrs[k]; !ok {
-			changes[k] = ""
+	for k := range oldA {
+		if _, ok := newA[k]; !ok {
+			unset = append(unset, k)
 		}
 	}
-	for k, v := range new.Events {
-		if old.Events[k] != v {
-			changes["data-forge-"+k] = v
+	slices.Sort(unset)
+	return set, unset
+}
+
+// renderedAttrs returns the attributes of e as the renderer writes them,
+// excluding data-forge-id. Names are lower-cased like the renderer's.
+func renderedAttrs(e ui.Element) map[string]string {
+	attrs := make(map[string]string, len(e.Attrs)+len(e.Events)+2)
+	if e.Class != "" {
+		attrs["class"] = e.Class
+	}
+	if e.Style != "" {
+		attrs["style"] = e.Style
+	}
+	for k, v := range e.Attrs {
+		if v, ok := render.AttrValue(k, v); ok {
+			attrs[strings.ToLower(k)] = v
 		}
 	}
-	for k := range old.Events {
-		if _, ok := new.Events[k]; !ok {
-			changes["data-forge-"+k] = ""
+	for k, v := range e.Events {
+		if render.ValidName(k) {
+			attrs["data-forge-"+k] = v
 		}
 	}
-	return changes
+	return attrs
 }
 
-func diffChildren(oldC, newC []ui.UI, parentPath string) []Patch {
+// diffChildren reconciles two child lists. Fragments are flattened first
+// (see render.Children), so every entry is a single DOM node with a path.
+//
+// Keyed children (elements with an ID) are matched by ID wherever they
+// moved; unkeyed children only match an unkeyed child at the same path.
+// Unmatched old children are removed first. Then, walking the new list
+// right to left, children outside the longest increasing subsequence of
+// old positions are moved before their already-placed successor and new
+// children are inserted there, so a reorder costs one move per displaced
+// element and an unkeyed list degrades to plain positional diffing.
+func diffChildren(oldC, newC []ui.UI, parentID string) []Patch {
+	oldK := render.Children(parentID, oldC)
+	newK := render.Children(parentID, newC)
+
+	oldByMatch := make(map[string]int, len(oldK))
+	for i, c := range oldK {
+		oldByMatch[matchKey(c)] = i
+	}
+
+	// sources[i] is the index in oldK matched by newK[i], or -1.
+	sources := make([]int, len(newK))
+	matched := make([]bool, len(oldK))
+	for i, c := range newK {
+		sources[i] = -1
+		if j, ok := oldByMatch[matchKey(c)]; ok && !matched[j] {
+			sources[i] = j
+			matched[j] = true
+		}
+	}
+
 	var patches []Patch
+	for i, c := range oldK {
+		if !matched[i] {
+			patches = append(patches, Patch{Type: Remove, ID: domID(c.Node, c.Path)})
+		}
+	}
 
-	oldByID := make(map[string]ui.UI)
-	for _, c := range oldC {
-		if e, ok := c.(ui.Element); ok && e.ID != "" {
-			oldByID[e.ID] = c
+	stable := lis(sources)
+	var content []Patch
+	before := ""
+	for i := len(newK) - 1; i >= 0; i-- {
+		c := newK[i]
+		id := domID(c.Node, c.Path)
+		switch j := sources[i]; {
+		case j < 0:
+			patches = append(patches, Patch{Type: Insert, ID: id, Parent: parentID, Before: before, HTML: render.HTMLAt(c.Node, c.Path)})
+		case !stable[i]:
+			patches = append(patches, Patch{Type: Move, ID: id, Parent: parentID, Before: before})
+			content = append(content, diffNode(oldK[j].Node, c.Node, c.Path)...)
+		default:
+			content = append(content, diffNode(oldK[j].Node, c.Node, c.Path)...)
+		}
+		if anchorable(c.Node) {
+			before = id
 		}
 	}
+	return append(patches, content...)
+}
 
-	usedIDs := make(map[string]bool)
-	for i, newChild := range newC {
-		childPath := parentPath + "." + strconv.Itoa(i)
-		var oldChild ui.UI
+// matchKey identifies a child across renders: keyed children by ID,
+// others by path.
+func matchKey(c render.Child) string {
+	if key := keyOf(c.Node); key != "" {
+		return "#" + key
+	}
+	return c.Path
+}
 
-		if e, ok := newChild.(ui.Element); ok && e.ID != "" {
-			if o, found := oldByID[e.ID]; found {
-				oldChild = o
-				usedIDs[e.ID] = true
-			}
+// lis marks the entries of seq that form a longest strictly increasing
+// subsequence, ignoring negative entries.
+func lis(seq []int) []bool {
+	var tails []int // indices into seq
+	prev := make([]int, len(seq))
+	for i, v := range seq {
+		if v < 0 {
+			continue
 		}
-		if oldChild == nil && i < len(oldC) {
-			o := oldC[i]
-			if e, ok := o.(ui.Element); ok && e.ID != "" {
-				if !usedIDs[e.ID] {
-					oldChild = nil
-				}
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if seq[tails[mid]] < v {
+				lo = mid + 1
 			} else {
-				oldChild = o
+				hi = mid
 			}
 		}
-		patches = append(patches, diffNode(oldChild, newChild, childPath)...)
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
 	}
 
-	for _, oldChild := range oldC {
-		if e, ok := oldChild.(ui.Element); ok && e.ID != "" && !usedIDs[e.ID] {
-			patches = append(patches, Patch{Type: Remove, ID: e.ID})
+	in := make([]bool, len(seq))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			in[i] = true
 		}
 	}
-	return patches
+	return in
+}
+
+func keyOf(n ui.UI) string {
+	switch n := ui.Unwrap(n).(type) {
+	case ui.Element:
+		return n.ID
+	case ui.IslandNode:
+		return n.ID
+	}
+	return ""
+}
+
+// domID returns the data-forge-id the node was rendered with at path.
+func domID(n ui.UI, path string) string {
+	if key := keyOf(n); key != "" {
+		return key
+	}
+	return path
+}
+
+// anchorable reports whether the client can locate a child by its ID:
+// elements by data-forge-id, text by its marker comment.
+func anchorable(n ui.UI) bool {
+	switch ui.Unwrap(n).(type) {
+	case ui.Element, ui.Text, ui.IslandNode:
+		return true
+	}
+	return false
 }
 
 func nodeType(n ui.UI) int {
@@ -172,6 +316,10 @@ func nodeType(n ui.UI) int {
 		return 2
 	case ui.Raw:
 		return 3
+	case ui.FragmentNode:
+		return 4
+	case ui.IslandNode:
+		return 5
 	}
 	return 0
 }
@@ -0,0 +1,183 @@
+package diff
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Shravanthh/forge/render"
+	"github.com/Shravanthh/forge/ui"
+)
+
+func TestDiffMixedCaseAttrs(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new ui.Element
+		want     []Patch
+	}{
+		{
+			"unsafe url",
+			ui.A(ui.T("x")).WithAttr("href", "/"),
+			ui.A(ui.T("x")).WithAttr("HREF", "javascript:alert(1)"),
+			[]Patch{{Type: UpdateAttr, ID: "0", Attrs: map[string]string{"href": render.BlockedURL}}},
+		},
+		{
+			"boolean off",
+			ui.Button(ui.T("x")).WithAttr("disabled", ""),
+			ui.Button(ui.T("x")).WithAttr("Disabled", "false"),
+			[]Patch{{Type: UpdateAttr, ID: "0", Attrs: map[string]string{}, Unset: []string{"disabled"}}},
+		},
+		{
+			"same attribute",
+			ui.Button(ui.T("x")).WithAttr("disabled", ""),
+			ui.Button(ui.T("x")).WithAttr("DISABLED", ""),
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(tt.old, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffChildren(t *testing.T) {
+	li := func(id string) ui.UI { return ui.Li(ui.T(id)).WithID(id) }
+	tests := []struct {
+		name     string
+		old, new []ui.UI
+		want     []Patch
+	}{
+		{
+			"keyed reorder",
+			[]ui.UI{li("a"), li("b"), li("c"), li("d")},
+			[]ui.UI{li("d"), li("a"), li("c"), li("b")},
+			[]Patch{
+				{Type: Move, ID: "c", Parent: "0", Before: "b"},
+				{Type: Move, ID: "d", Parent: "0", Before: "a"},
+			},
+		},
+		{
+			"keyed insert",
+			[]ui.UI{li("a"), li("c")},
+			[]ui.UI{li("a"), li("b"), li("c"), li("d")},
+			[]Patch{
+				{Type: Insert, ID: "d", Parent: "0", HTML: `<li data-forge-id="d">d</li>`},
+				{Type: Insert, ID: "b", Parent: "0", Before: "c", HTML: `<li data-forge-id="b">b</li>`},
+			},
+		},
+		{
+			"keyed remove",
+			[]ui.UI{li("a"), li("b"), li("c")},
+			[]ui.UI{li("a"), li("c")},
+			[]Patch{{Type: Remove, ID: "b"}},
+		},
+		{
+			"remove, insert and move",
+			[]ui.UI{li("a"), li("b"), li("c")},
+			[]ui.UI{li("c"), li("x"), li("a")},
+			[]Patch{
+				{Type: Remove, ID: "b"},
+				{Type: Insert, ID: "x", Parent: "0", Before: "a", HTML: `<li data-forge-id="x">x</li>`},
+				{Type: Move, ID: "c", Parent: "0", Before: "x"},
+			},
+		},
+		{
+			"unkeyed remove",
+			[]ui.UI{ui.Li(ui.T("x")), ui.Li(ui.T("y"))},
+			[]ui.UI{ui.Li(ui.T("x"))},
+			[]Patch{{Type: Remove, ID: "0.1"}},
+		},
+		{
+			"unkeyed insert",
+			[]ui.UI{ui.Li(ui.T("x"))},
+			[]ui.UI{ui.Li(ui.T("x")), ui.Li(ui.T("y"))},
+			[]Patch{{Type: Insert, ID: "0.1", Parent: "0", HTML: `<li data-forge-id="0.1">y</li>`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(ui.Ul(tt.old...), ui.Ul(tt.new...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDiffChildrenOrder replays the patches for keyed lists on the list of
+// old IDs and checks that they give the new order with one move per
+// element outside the longest run kept in place.
+func TestDiffChildrenOrder(t *testing.T) {
+	tests := []struct {
+		old, new string
+		moves    int
+	}{
+		{"abcde", "abcde", 0},
+		{"abcde", "edcba", 4},
+		{"abcde", "bcdea", 1},
+		{"abcde", "eabcd", 1},
+		{"abcde", "acbed", 2},
+		{"abcde", "xaeyc", 1},
+		{"abc", "", 0},
+		{"", "abc", 0},
+	}
+	keyed := func(ids string) []ui.UI {
+		var children []ui.UI
+		for _, id := range ids {
+			children = append(children, ui.Li(ui.T(string(id))).WithID(string(id)))
+		}
+		return children
+	}
+	for _, tt := range tests {
+		patches := Diff(ui.Ul(keyed(tt.old)...), ui.Ul(keyed(tt.new)...))
+		ids := make([]string, 0, len(tt.old))
+		for _, id := range tt.old {
+			ids = append(ids, string(id))
+		}
+		moves := 0
+		for _, p := range patches {
+			switch p.Type {
+			case Remove:
+				ids = slices.DeleteFunc(ids, func(id string) bool { return id == p.ID })
+			case Move:
+				moves++
+				ids = slices.DeleteFunc(ids, func(id string) bool { return id == p.ID })
+				fallthrough
+			case Insert:
+				at := len(ids)
+				if p.Before != "" {
+					at = slices.Index(ids, p.Before)
+				}
+				ids = slices.Insert(ids, at, p.ID)
+			}
+		}
+		if got := strings.Join(ids, ""); got != tt.new || moves != tt.moves {
+			t.Errorf("%q -> %q: patches give %q with %d moves; want %d moves\n%+v", tt.old, tt.new, got, moves, tt.moves, patches)
+		}
+	}
+}
+
+func TestLIS(t *testing.T) {
+	tests := []struct {
+		seq  []int
+		want []bool
+	}{
+		{nil, []bool{}},
+		{[]int{-1, -1}, []bool{false, false}},
+		{[]int{0, 1, 2}, []bool{true, true, true}},
+		{[]int{2, 1, 0}, []bool{false, false, true}},
+		{[]int{3, 0, 1, 2}, []bool{false, true, true, true}},
+		{[]int{0, -1, 2, 1, 3}, []bool{true, false, false, true, true}},
+		{[]int{1, 1, 2}, []bool{false, true, true}},
+	}
+	for _, tt := range tests {
+		if got := lis(tt.seq); !slices.Equal(got, tt.want) {
+			t.Errorf("lis(%v) = %v; want %v", tt.seq, got, tt.want)
+		}
+	}
+}
@@ -37,10 +37,10 @@
 // Forge follows a server-driven UI architecture:
 //
 //  1. Server renders UI to HTML and sends to browser
-//  2. Browser loads minimal WASM client (425KB)
+//  2. Browser loads the client runtime (WASM, or a small JS client)
 //  3. User interactions are sent to server via WebSocket
 //  4. Server updates state, re-renders, and sends DOM patches
-//  5. WASM client applies patches to update the DOM
+//  5. The client applies patches to update the DOM
 //
 // # Features
 //
@@ -56,6 +56,8 @@
 package forge
 
 import (
+	"net/http"
+
 	"github.com/Shravanthh/forge/ctx"
 	"github.com/Shravanthh/forge/server"
 )
@@ -86,7 +88,7 @@ type DevServer = server.DevServer
 type PageFunc = server.PageFunc
 
 // LayoutFunc wraps page content with a layout.
-// Layouts are applied based on URL prefix matching.
+// Layouts are applied based on URL prefix matching, innermost first.
 //
 //	app.Layout("/", func(c *forge.Context, child ui.UI) ui.UI {
 //	    return ui.Div(
@@ -96,6 +98,37 @@ type PageFunc = server.PageFunc
 //	})
 type LayoutFunc = server.LayoutFunc
 
+// RouteOption configures a route registered with App.Route.
+type RouteOption = server.RouteOption
+
+// RouteInfo describes a registered route. See App.Routes.
+type RouteInfo = server.RouteInfo
+
+// Group registers routes under a common prefix with their own layouts,
+// middleware and guards. See App.Group.
+type Group = server.Group
+
+// Guard decides whether a page may render for the current session.
+//
+//	app.Group("/admin", func(g *forge.Group) {
+//	    g.Guard(server.RequireRole("admin"))
+//	    g.Route("/", AdminPage)
+//	})
+type Guard = server.Guard
+
+// Client selects the browser runtime served with every page.
+//
+//	app := forge.New()
+//	app.UseClient(forge.ClientJS)
+type Client = server.Client
+
+// Client runtimes. ClientWASM is the default; ClientJS is a small
+// hand-written JavaScript client implementing the same protocol.
+const (
+	ClientWASM = server.ClientWASM
+	ClientJS   = server.ClientJS
+)
+
 // New creates a new Forge application for production use.
 //
 //	app := forge.New()
@@ -103,6 +136,42 @@ type LayoutFunc = server.LayoutFunc
 //	app.Run(":3000")
 func New() *App { return server.New() }
 
+// NoLayout excludes layouts from a route: every layout when called
+// without arguments, otherwise only those registered at prefixes.
+//
+//	app.Layout("/admin", AdminLayout)
+//	app.Route("/admin/login", LoginPage, forge.NoLayout("/admin"))
+func NoLayout(prefixes ...string) RouteOption { return server.NoLayout(prefixes...) }
+
+// Name names a route for App.URL, c.URL and Element.Route.
+//
+//	app.Route("/user/:id", UserPage, forge.Name("user"))
+//	app.URL("user", "id", "42") // "/user/42"
+func Name(name string) RouteOption { return server.Name(name) }
+
+// JSON adapts a typed function to a JSON endpoint: the request body is
+// validated against schema with ctx.ValidateJSON (nil to skip) and decoded
+// into an In, and the result is encoded as the response.
+//
+//	app.Post("/api/todos", forge.JSON(map[string]string{"title": "required|string"},
+//	    func(r *http.Request, in NewTodo) (Todo, error) {
+//	        return store.Add(in.Title)
+//	    }))
+func JSON[In, Out any](schema map[string]string, fn func(r *http.Request, in In) (Out, error)) http.HandlerFunc {
+	return server.JSON(schema, fn)
+}
+
+// DecodeJSON validates the request body against schema (nil to skip) and
+// decodes it into a T.
+func DecodeJSON[T any](r *http.Request, schema map[string]string) (T, error) {
+	return server.DecodeJSON[T](r, schema)
+}
+
+// WriteJSON sends v as JSON with status.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	return server.WriteJSON(w, status, v)
+}
+
 // NewDev creates a development server with hot reload.
 // Pass the directory to watch for file changes.
 //
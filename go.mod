@@ -3,3 +3,5 @@ module github.com/Shravanthh/forge
 go 1.25
 
 require github.com/gorilla/websocket v1.5.3
+
+require github.com/andybalholm/brotli v1.2.0
@@ -0,0 +1,69 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/Shravanthh/forge/ui"
+)
+
+// BoolAttrs lists boolean attributes. They are written without a value
+// when on and omitted when their value is "false"; ui.Element.WithBool
+// sets them.
+var BoolAttrs = map[string]bool{
+	"disabled": true, "checked": true, "readonly": true, "selected": true,
+	"required": true, "multiple": true, "hidden": true, "autofocus": true,
+	"open": true, "novalidate": true, "formnovalidate": true, "inert": true,
+	"async": true, "defer": true, "autoplay": true, "controls": true,
+	"loop": true, "muted": true, "playsinline": true, "reversed": true,
+	"ismap": true, "allowfullscreen": true, "default": true, "nomodule": true,
+}
+
+// URLAttrs lists attributes whose values are URLs the browser may
+// navigate to or load.
+var URLAttrs = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true,
+	"poster": true, "xlink:href": true,
+}
+
+// BlockedURL replaces URLs with unsafe schemes.
+const BlockedURL = "about:invalid#forge-blocked"
+
+// ValidName reports whether s is safe to write as a tag or attribute name:
+// an ASCII letter followed by letters, digits, '-', '_', '.' or ':'.
+func ValidName(s string) bool {
+	if s == "" || !isLetter(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !isLetter(c) && (c < '0' || c > '9') && !strings.ContainsRune("-_.:", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool { return c|0x20 >= 'a' && c|0x20 <= 'z' }
+
+// AttrValue returns the value an attribute is rendered with, or false if
+// it is omitted: invalid names are dropped, boolean attributes set to
+// "false" are off, and URLs with unsafe schemes (javascript:, vbscript:,
+// non-media data:) are replaced with BlockedURL. HTML attribute names are
+// case-insensitive, so name is matched in lower case. Diffs use it too,
+// so patches never set what the renderer would not write.
+func AttrValue(name, value string) (string, bool) {
+	if !ValidName(name) {
+		return "", false
+	}
+	name = strings.ToLower(name)
+	if BoolAttrs[name] {
+		if strings.EqualFold(value, "false") {
+			return "", false
+		}
+		return "", true
+	}
+	if URLAttrs[name] && !ui.SafeURL(value) {
+		return BlockedURL, true
+	}
+	return value, true
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shravanthh/forge/ui"
+)
+
+func TestAttrValue(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        string
+		ok          bool
+	}{
+		{"href", "/docs", "/docs", true},
+		{"href", "javascript:alert(1)", BlockedURL, true},
+		{"HREF", "javascript:alert(1)", BlockedURL, true},
+		{"Src", "JavaScript:alert(1)", BlockedURL, true},
+		{"XLink:Href", "javascript:alert(1)", BlockedURL, true},
+		{"disabled", "", "", true},
+		{"disabled", "false", "", false},
+		{"Disabled", "false", "", false},
+		{"CHECKED", "FALSE", "", false},
+		{"Checked", "", "", true},
+		{"title", "false", "false", true},
+		{"on click", "x", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := AttrValue(tt.name, tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("AttrValue(%q, %q) = %q, %v; want %q, %v", tt.name, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestHTMLMixedCaseAttrs(t *testing.T) {
+	tests := []struct {
+		el      ui.Element
+		want    string
+		notWant string
+	}{
+		{ui.A(ui.T("x")).WithAttr("HREF", "javascript:alert(1)"), ` href="` + BlockedURL + `"`, "javascript:"},
+		{ui.Button(ui.T("x")).WithAttr("Disabled", "false"), "", "disabled"},
+		{ui.Button(ui.T("x")).WithAttr("Disabled", ""), " disabled>", `disabled=""`},
+	}
+	for _, tt := range tests {
+		got := HTML(tt.el)
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("HTML = %s; want it to contain %q", got, tt.want)
+		}
+		if strings.Contains(strings.ToLower(got), tt.notWant) {
+			t.Errorf("HTML = %s; want no %q", got, tt.notWant)
+		}
+	}
+}
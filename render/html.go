@@ -1,14 +1,33 @@
-// Package render converts UI trees to HTML strings.
+// Package render converts UI trees to HTML.
+//
+// Output is deterministic: attributes and event bindings are written in
+// sorted order, so the same tree always renders to the same bytes.
 package render
 
 import (
-	"html"
+	"bufio"
+	"io"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Shravanthh/forge/ui"
 )
 
+// writer is implemented by strings.Builder and bufio.Writer.
+type writer interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+// escaper matches html.EscapeString but writes without allocating.
+var escaper = strings.NewReplacer(`&`, "&amp;", `'`, "&#39;", `<`, "&lt;", `>`, "&gt;", `"`, "&#34;")
+
+var bufPool = sync.Pool{New: func() any { return bufio.NewWriterSize(nil, 8192) }}
+
 var selfClosing = map[string]bool{
 	"input": true, "img": true, "br": true, "hr": true,
 	"meta": true, "link": true, "area": true, "base": true,
@@ -16,75 +35,230 @@ var selfClosing = map[string]bool{
 
 // HTML renders a UI tree to an HTML string.
 // Each element receives a data-forge-id attribute for DOM patching.
+// Elements with an ID use it as data-forge-id and as the path prefix of
+// their children, so keyed subtrees keep stable IDs when they move.
 //
 //	tree := ui.Div(ui.H1(ui.T("Hello")))
 //	html := render.HTML(tree)
 //	// <div data-forge-id="0"><h1 data-forge-id="0.0">Hello</h1></div>
 func HTML(node ui.UI) string {
+	return HTMLAt(node, "0")
+}
+
+// Write streams a UI tree as HTML to w through a pooled buffer.
+// It produces the same output as HTML without building the whole
+// document in memory.
+func Write(w io.Writer, node ui.UI) error {
+	bw := bufPool.Get().(*bufio.Writer)
+	bw.Reset(w)
+	renderNode(bw, node, "0")
+	err := bw.Flush()
+	bw.Reset(nil)
+	bufPool.Put(bw)
+	return err
+}
+
+// HTMLAt renders a subtree as if it were located at path in the page.
+// Used for patches that insert or replace part of a rendered page.
+// A text node is rendered with its marker comment, since patches only
+// insert or replace text among siblings.
+func HTMLAt(node ui.UI, path string) string {
 	var b strings.Builder
 	b.Grow(4096)
-	renderNode(&b, node, "0")
+	if _, ok := ui.Unwrap(node).(ui.Text); ok {
+		writeTextMarker(&b, path)
+	}
+	renderNode(&b, node, path)
 	return b.String()
 }
 
-func renderNode(b *strings.Builder, node ui.UI, path string) {
+// Child is a node of a flattened child list with the path it renders at.
+type Child struct {
+	Node ui.UI
+	Path string
+}
+
+// Children flattens fragments in a child list. The j-th node of a
+// fragment at index i renders at parentID.i.j, so a fragment growing or
+// shrinking never shifts the paths of the siblings after it.
+func Children(parentID string, children []ui.UI) []Child {
+	var out []Child
+	appendChildren(&out, parentID, children)
+	return out
+}
+
+func appendChildren(out *[]Child, prefix string, children []ui.UI) {
+	for i, child := range children {
+		path := prefix + "." + strconv.Itoa(i)
+		switch n := ui.Unwrap(child).(type) {
+		case nil:
+		case ui.FragmentNode:
+			appendChildren(out, path, memoChildren(child, n))
+		default:
+			*out = append(*out, Child{Node: child, Path: path})
+		}
+	}
+}
+
+// memoChildren returns the children of fragment n. If node is a Memo
+// holding n, each child is wrapped in a Memoized of the same generation,
+// so flattening the fragment keeps the memo boundary for diffing.
+func memoChildren(node ui.UI, n ui.FragmentNode) []ui.UI {
+	var memo ui.Memoized
+	found := false
+	for {
+		m, ok := node.(ui.Memoized)
+		if !ok {
+			break
+		}
+		memo, found, node = m, true, m.Node
+	}
+	if !found {
+		return n.Children
+	}
+	children := make([]ui.UI, len(n.Children))
+	for j, child := range n.Children {
+		children[j] = ui.Memoized{Key: memo.Key + "." + strconv.Itoa(j), Gen: memo.Gen, Node: child}
+	}
+	return children
+}
+
+// TextOnly reports whether an element's only child is a text node.
+// Such text is addressed through its parent; text with siblings is
+// preceded by a <!--path--> marker comment so it can be patched alone.
+func TextOnly(e ui.Element) bool {
+	for _, child := range e.Children {
+		if _, ok := ui.Unwrap(child).(ui.FragmentNode); ok {
+			return textOnly(Children("", e.Children))
+		}
+	}
+	if len(e.Children) != 1 {
+		return false
+	}
+	_, ok := ui.Unwrap(e.Children[0]).(ui.Text)
+	return ok
+}
+
+func textOnly(children []Child) bool {
+	if len(children) != 1 {
+		return false
+	}
+	_, ok := ui.Unwrap(children[0].Node).(ui.Text)
+	return ok
+}
+
+func writeTextMarker(b writer, path string) {
+	b.WriteString("<!--")
+	markerEscaper.WriteString(b, path)
+	b.WriteString("-->")
+}
+
+// markerEscaper keeps IDs from closing or nesting a marker comment.
+// The client applies the same escaping when it looks markers up.
+var markerEscaper = strings.NewReplacer(`%`, "%25", `<`, "%3C", `>`, "%3E")
+
+func renderNode(b writer, node ui.UI, path string) {
 	switch n := node.(type) {
 	case ui.Element:
 		renderElement(b, n, path)
 	case ui.Text:
-		b.WriteString(html.EscapeString(n.Value))
+		escaper.WriteString(b, n.Value)
 	case ui.Raw:
 		b.WriteString(n.HTML)
+	case ui.Memoized:
+		renderNode(b, n.Node, path)
+	case ui.IslandNode:
+		renderElement(b, islandElement(n), path)
+	case ui.FragmentNode:
+		for _, child := range Children(path, n.Children) {
+			if _, ok := ui.Unwrap(child.Node).(ui.Text); ok {
+				writeTextMarker(b, child.Path)
+			}
+			renderNode(b, child.Node, child.Path)
+		}
 	}
 }
 
-func renderElement(b *strings.Builder, e ui.Element, path string) {
+func renderElement(b writer, e ui.Element, path string) {
 	id := path
 	if e.ID != "" {
 		id = e.ID
 	}
+	tag := e.Tag
+	if !ValidName(tag) {
+		// A malformed tag could inject markup; render a plain div instead.
+		tag = "div"
+	}
 
 	b.WriteByte('<')
-	b.WriteString(e.Tag)
+	b.WriteString(tag)
 	b.WriteString(` data-forge-id="`)
-	b.WriteString(id)
+	escaper.WriteString(b, id)
 	b.WriteByte('"')
 
 	if e.Class != "" {
 		b.WriteString(` class="`)
-		b.WriteString(html.EscapeString(e.Class))
+		escaper.WriteString(b, e.Class)
 		b.WriteByte('"')
 	}
 	if e.Style != "" {
 		b.WriteString(` style="`)
-		b.WriteString(html.EscapeString(e.Style))
+		escaper.WriteString(b, e.Style)
 		b.WriteByte('"')
 	}
-	for k, v := range e.Attrs {
+	for _, k := range sortedKeys(e.Attrs) {
+		v, ok := AttrValue(k, e.Attrs[k])
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(k)
 		b.WriteByte(' ')
-		b.WriteString(k)
-		b.WriteString(`="`)
-		b.WriteString(html.EscapeString(v))
-		b.WriteByte('"')
+		b.WriteString(name)
+		if !BoolAttrs[name] {
+			b.WriteString(`="`)
+			escaper.WriteString(b, v)
+			b.WriteByte('"')
+		}
 	}
-	for evt, handler := range e.Events {
+	for _, evt := range sortedKeys(e.Events) {
+		if !ValidName(evt) {
+			continue
+		}
 		b.WriteString(` data-forge-`)
 		b.WriteString(evt)
 		b.WriteString(`="`)
-		b.WriteString(handler)
+		escaper.WriteString(b, e.Events[evt])
 		b.WriteByte('"')
 	}
 
-	if selfClosing[e.Tag] {
+	children := Children(id, e.Children)
+	// SVG and MathML elements close themselves when empty (<circle />).
+	if selfClosing[tag] && e.NS == "" || e.NS != "" && len(children) == 0 {
 		b.WriteString(" />")
 		return
 	}
 
 	b.WriteByte('>')
-	for i, child := range e.Children {
-		renderNode(b, child, path+"."+strconv.Itoa(i))
+	marked := !textOnly(children)
+	for _, child := range children {
+		if _, ok := ui.Unwrap(child.Node).(ui.Text); ok && marked {
+			writeTextMarker(b, child.Path)
+		}
+		renderNode(b, child.Node, child.Path)
 	}
 	b.WriteString("</")
-	b.WriteString(e.Tag)
+	b.WriteString(tag)
 	b.WriteByte('>')
 }
+
+func sortedKeys(m map[string]string) []string {
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		for k := range m {
+			return []string{k}
+		}
+	}
+	return slices.Sorted(maps.Keys(m))
+}
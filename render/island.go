@@ -0,0 +1,24 @@
+package render
+
+import (
+	"encoding/json"
+
+	"github.com/Shravanthh/forge/ui"
+)
+
+// islandElement is the wrapper an island renders in. The client finds
+// islands by data-forge-island and mounts them from data-forge-props;
+// the island's own tree renders at the wrapper's ID + ".0".
+func islandElement(n ui.IslandNode) ui.Element {
+	props, _ := json.Marshal(n.Props)
+	return ui.Element{
+		Tag:   "div",
+		ID:    n.ID,
+		Style: "display:contents",
+		Attrs: map[string]string{
+			"data-forge-island": n.Name,
+			"data-forge-props":  string(props),
+		},
+		Children: []ui.UI{n.Node},
+	}
+}
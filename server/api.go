@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// maxJSONBody limits the request bodies JSON decodes.
+const maxJSONBody = 1 << 20 // 1MB
+
+// Handle registers an HTTP endpoint for method at path. The path uses
+// the same patterns as pages; parameters are read with r.PathValue.
+// Another method on a path with endpoints or a page answers 405.
+func (a *App) Handle(method, path string, h http.Handler) {
+	a.router.AddHandler(method, path, h)
+}
+
+// Get registers a GET endpoint (also answering HEAD).
+//
+//	app.Get("/api/users/:id<int>", func(w http.ResponseWriter, r *http.Request) {
+//	    server.WriteJSON(w, http.StatusOK, store.User(r.PathValue("id")))
+//	})
+func (a *App) Get(path string, h http.HandlerFunc) { a.Handle(http.MethodGet, path, h) }
+
+// Post registers a POST endpoint.
+func (a *App) Post(path string, h http.HandlerFunc) { a.Handle(http.MethodPost, path, h) }
+
+// Put registers a PUT endpoint.
+func (a *App) Put(path string, h http.HandlerFunc) { a.Handle(http.MethodPut, path, h) }
+
+// Delete registers a DELETE endpoint.
+func (a *App) Delete(path string, h http.HandlerFunc) { a.Handle(http.MethodDelete, path, h) }
+
+// Handle registers an endpoint within the group. The group's middleware
+// wraps it, and its guards run first with a Context carrying the route
+// parameters, query and roles of the request.
+func (g *Group) Handle(method, path string, h http.Handler) {
+	g.app.router.AddHandler(method, g.path(path), h, func(r *Route) { r.group = g })
+}
+
+// Get registers a GET endpoint within the group.
+func (g *Group) Get(path string, h http.HandlerFunc) { g.Handle(http.MethodGet, path, h) }
+
+// Post registers a POST endpoint within the group.
+func (g *Group) Post(path string, h http.HandlerFunc) { g.Handle(http.MethodPost, path, h) }
+
+// Put registers a PUT endpoint within the group.
+func (g *Group) Put(path string, h http.HandlerFunc) { g.Handle(http.MethodPut, path, h) }
+
+// Delete registers a DELETE endpoint within the group.
+func (g *Group) Delete(path string, h http.HandlerFunc) { g.Handle(http.MethodDelete, path, h) }
+
+// guardEndpoint runs guards before h. A refusal is answered like a page's:
+// a redirect, 404 for ErrNotFound, and 403 otherwise.
+func (a *App) guardEndpoint(h http.Handler, guards []Guard, params map[string]string) http.Handler {
+	if len(guards) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := a.newContext()
+		c.Params = params
+		c.SetQuery(r.URL.Query())
+		c.SetRoles(requestRoles(r)...)
+		if err := checkGuards(c, guards); err != nil {
+			var redirect *RedirectError
+			switch {
+			case errors.As(err, &redirect):
+				http.Redirect(w, r, redirect.URL, http.StatusSeeOther)
+			case errors.Is(err, ErrNotFound):
+				http.NotFound(w, r)
+			default:
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			}
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ValidationError reports request fields that failed validation.
+type ValidationError struct {
+	Errors map[string]string // Field name to message; "_json" for malformed JSON
+}
+
+func (e *ValidationError) Error() string { return "invalid request body" }
+
+// StatusError is an error with the HTTP status to answer it with.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string { return e.Message }
+
+// Error returns an error that JSON endpoints answer with status and
+// {"error": message}.
+//
+//	return nil, server.Error(http.StatusNotFound, "no such user")
+func Error(status int, message string) error {
+	return &StatusError{Status: status, Message: message}
+}
+
+// DecodeJSON reads the request body as JSON into a T. If schema is not
+// nil the body is first checked with ctx.ValidateJSON, and failures are
+// returned as a *ValidationError.
+func DecodeJSON[T any](r *http.Request, schema map[string]string) (T, error) {
+	var v T
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxJSONBody+1))
+	if err != nil {
+		return v, err
+	}
+	if len(data) > maxJSONBody {
+		return v, Error(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+	if schema != nil {
+		if errs := ctx.ValidateJSON(data, schema); len(errs) > 0 {
+			return v, &ValidationError{Errors: errs}
+		}
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, &ValidationError{Errors: map[string]string{"_json": "Invalid JSON"}}
+	}
+	return v, nil
+}
+
+// WriteJSON sends v as JSON with status.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError answers err: 400 with the fields of a ValidationError,
+// the status of a StatusError, and 500 (without details) otherwise.
+func writeJSONError(w http.ResponseWriter, err error) {
+	var invalid *ValidationError
+	var status *StatusError
+	switch {
+	case errors.As(err, &invalid):
+		WriteJSON(w, http.StatusBadRequest, map[string]any{"errors": invalid.Errors})
+	case errors.As(err, &status):
+		WriteJSON(w, status.Status, map[string]string{"error": status.Message})
+	default:
+		log.Printf("endpoint error: %v", err)
+		WriteJSON(w, http.StatusInternalServerError, map[string]string{"error": "Internal Server Error"})
+	}
+}
+
+// JSON adapts a typed function to a JSON endpoint. The request body is
+// validated against schema (nil to skip) and decoded into an In; fn's
+// result is sent with status 200, and its error as described for Error.
+// A GET, HEAD or DELETE request without a body gets a zero In.
+//
+//	type NewUser struct {
+//	    Name  string `json:"name"`
+//	    Email string `json:"email"`
+//	}
+//
+//	app.Post("/api/users", server.JSON(map[string]string{
+//	    "name":  "required|string",
+//	    "email": "required|string|email",
+//	}, func(r *http.Request, in NewUser) (User, error) {
+//	    return store.CreateUser(in.Name, in.Email)
+//	}))
+func JSON[In, Out any](schema map[string]string, fn func(r *http.Request, in In) (Out, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in In
+		if r.ContentLength != 0 || !bodyless(r.Method) {
+			var err error
+			if in, err = DecodeJSON[In](r, schema); err != nil {
+				writeJSONError(w, err)
+				return
+			}
+		}
+		out, err := fn(r, in)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, out)
+	}
+}
+
+func bodyless(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+func TestGroupEndpointGuards(t *testing.T) {
+	app := New()
+	app.Group("/api", func(g *Group) {
+		g.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if role := r.Header.Get("X-Role"); role != "" {
+					r = WithRoles(r, role)
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		g.Get("/items/:id", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("item " + r.PathValue("id")))
+		})
+		g.Guard(RequireRole("admin"), func(c *ctx.Context) error {
+			switch c.Params["id"] {
+			case "0":
+				return ErrNotFound
+			case "old":
+				return Redirect("/api/items/new")
+			}
+			return nil
+		})
+	})
+	app.Get("/open", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("open")) })
+
+	tests := []struct {
+		path, role string
+		status     int
+		body       string // Checked if not empty; the Location header for 303
+	}{
+		{"/api/items/1", "", http.StatusForbidden, ""},
+		{"/api/items/1", "user", http.StatusForbidden, ""},
+		{"/api/items/1", "admin", http.StatusOK, "item 1"},
+		{"/api/items/0", "admin", http.StatusNotFound, ""},
+		{"/api/items/old", "admin", http.StatusSeeOther, "/api/items/new"},
+		{"/open", "", http.StatusOK, "open"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.role != "" {
+			r.Header.Set("X-Role", tt.role)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		body := w.Body.String()
+		if w.Code == http.StatusSeeOther {
+			body = w.Header().Get("Location")
+		}
+		if w.Code != tt.status || (tt.body != "" && body != tt.body) {
+			t.Errorf("GET %s as %q = %d %q; want %d %q", tt.path, tt.role, w.Code, body, tt.status, tt.body)
+		}
+	}
+}
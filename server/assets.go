@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Rebuild the WASM client and record the sources it was built from;
+// TestWASMBundle fails until this is run after changing them.
+//
+//go:generate tinygo build -o wasm/forge.wasm -target wasm -no-debug ./wasm
+//go:generate go test -run TestWASMBundle -update .
+
+// runtimeFS holds the client runtimes and any precompressed variants
+// produced at build time (forge.wasm.br, forge.js.gz, ...).
+//
+//go:embed wasm/forge.wasm* wasm/wasm_exec.js* client/forge.js*
+var runtimeFS embed.FS
+
+// assetPrefix is the URL prefix of content-hashed runtime assets.
+const assetPrefix = "/_forge/"
+
+// assetCacheControl lets browsers and CDNs keep hashed assets forever:
+// a new deploy with different content gets a different URL.
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// asset is a runtime file served at a content-hashed URL.
+type asset struct {
+	url         string // e.g. /_forge/forge.3f9a1b2c4d.wasm
+	contentType string
+	hash        string
+	raw, gz, br []byte
+}
+
+type assetSet struct {
+	wasm, wasmExec, js *asset
+	byURL              map[string]*asset
+}
+
+// runtimeAssets loads and compresses the runtime once, on first use.
+// New loads it, so the cost is paid at startup rather than by the first
+// request, whether the app is started with Run or mounted on a mux.
+var runtimeAssets = sync.OnceValue(func() *assetSet {
+	s := &assetSet{
+		wasm:     loadAsset("wasm/forge.wasm", "application/wasm"),
+		wasmExec: loadAsset("wasm/wasm_exec.js", "text/javascript; charset=utf-8"),
+		js:       loadAsset("client/forge.js", "text/javascript; charset=utf-8"),
+	}
+	s.byURL = map[string]*asset{s.wasm.url: s.wasm, s.wasmExec.url: s.wasmExec, s.js.url: s.js}
+	return s
+})
+
+// loadAsset reads an embedded file and its precompressed variants.
+// Missing variants are compressed here.
+func loadAsset(name, contentType string) *asset {
+	raw, err := runtimeFS.ReadFile(name)
+	if err != nil {
+		panic("forge: missing embedded runtime " + name)
+	}
+	gz, _ := runtimeFS.ReadFile(name + ".gz")
+	br, _ := runtimeFS.ReadFile(name + ".br")
+	return newAsset(path.Base(name), contentType, raw, gz, br)
+}
+
+// newAsset hashes raw into a URL derived from name, and gzips and
+// brotli-compresses it unless gz and br are given.
+func newAsset(name, contentType string, raw, gz, br []byte) *asset {
+	sum := sha256.Sum256(raw)
+	hash := hex.EncodeToString(sum[:5])
+
+	ext := path.Ext(name)
+	a := &asset{
+		url:         assetPrefix + strings.TrimSuffix(name, ext) + "." + hash + ext,
+		contentType: contentType,
+		hash:        hash,
+		raw:         raw,
+		gz:          gz,
+		br:          br,
+	}
+	if a.gz == nil {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(raw)
+		zw.Close()
+		a.gz = buf.Bytes()
+	}
+	if a.br == nil {
+		var buf bytes.Buffer
+		// Quality 11 takes seconds on forge.wasm, slowing every dev
+		// restart, for 10% less; ship forge.wasm.br to get it.
+		bw := brotli.NewWriterLevel(&buf, 9)
+		bw.Write(raw)
+		bw.Close()
+		a.br = buf.Bytes()
+	}
+	return a
+}
+
+// serve writes the best encoding the client accepts, or 304 if its
+// cached copy is current.
+func (a *asset) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	body, encoding := a.raw, ""
+	accept := r.Header.Get("Accept-Encoding")
+	if acceptsEncoding(accept, "br") {
+		body, encoding = a.br, "br"
+	} else if acceptsEncoding(accept, "gzip") {
+		body, encoding = a.gz, "gzip"
+	}
+
+	etag := `"` + a.hash + `"`
+	if encoding != "" {
+		etag = `"` + a.hash + "-" + encoding + `"`
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", assetCacheControl)
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept-Encoding")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", a.contentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == "HEAD" {
+		return
+	}
+	w.Write(body)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows coding
+// with a non-zero quality, either by name or through "*".
+func acceptsEncoding(header, coding string) bool {
+	star := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case coding:
+			return q > 0
+		case "*":
+			star = q > 0
+		}
+	}
+	return star
+}
+
+// etagMatches reports whether If-None-Match names etag, the quoted tag
+// of the encoding being served. Each encoding has its own tag: a cache
+// holding the gzip body must not be told it is current for brotli.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" {
+			return true
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
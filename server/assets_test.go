@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite wasm/bundle.sum for the current forge.wasm and sources")
+
+// module is the import path prefix of packages inside this repository.
+const module = "github.com/Shravanthh/forge/"
+
+// bundleSum records which sources wasm/forge.wasm was built from. It is
+// not named forge.wasm.* so that runtimeFS does not embed it.
+const bundleSum = "wasm/bundle.sum"
+
+// TestWASMBundle fails when wasm/forge.wasm was not rebuilt after a change
+// to the Go sources of the WASM client, or was rebuilt without updating
+// wasm/bundle.sum. `go generate ./server` does both.
+func TestWASMBundle(t *testing.T) {
+	source, err := wasmSourceSum("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wasm, err := os.ReadFile("wasm/forge.wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := sha256.Sum256(wasm)
+	want := map[string]string{"source": source, "forge.wasm": hex.EncodeToString(binary[:])}
+
+	if *update {
+		body := "# Written by `go generate ./server` after building forge.wasm; do not edit.\n" +
+			"source " + want["source"] + "\n" +
+			"forge.wasm " + want["forge.wasm"] + "\n"
+		if err := os.WriteFile(bundleSum, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	got, err := readBundleSum(bundleSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case got["forge.wasm"] != want["forge.wasm"]:
+		t.Errorf("%s does not describe wasm/forge.wasm; rebuild it with `go generate ./server` (needs tinygo)", bundleSum)
+	case got["source"] != want["source"]:
+		t.Errorf("wasm/forge.wasm was built from different sources; rebuild it with `go generate ./server` (needs tinygo)")
+	}
+}
+
+// readBundleSum parses the "name hash" lines of a bundle.sum file.
+func readBundleSum(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := map[string]string{}
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, sum, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s: malformed line %q", name, line)
+		}
+		sums[key] = sum
+	}
+	return sums, lines.Err()
+}
+
+// wasmSourceSum hashes the Go files of every package in the repository
+// rooted at root that server/wasm imports when built for js/wasm.
+func wasmSourceSum(root string) (string, error) {
+	bctx := build.Default
+	bctx.GOOS, bctx.GOARCH = "js", "wasm"
+
+	var files []string
+	seen := map[string]bool{}
+	queue := []string{"server/wasm"}
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		pkg, err := bctx.ImportDir(filepath.Join(root, dir), 0)
+		if err != nil {
+			return "", err
+		}
+		for _, f := range pkg.GoFiles {
+			files = append(files, dir+"/"+f)
+		}
+		for _, imp := range pkg.Imports {
+			if rel, ok := strings.CutPrefix(imp, module); ok {
+				queue = append(queue, rel)
+			}
+		}
+	}
+	slices.Sort(files)
+
+	h := sha256.New()
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(root, f))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", f, len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
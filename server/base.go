@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BasePath mounts the app under prefix, so it can be served by an
+// existing mux:
+//
+//	app := forge.New()
+//	app.BasePath("/ui")
+//	mux.Handle("/ui/", app)
+//
+// Requests are routed with the prefix removed: the page at "/users" is
+// served at /ui/users. The runtime endpoints (/ui/ws, /ui/_forge/...)
+// move with it, and the client learns them from a config rendered into
+// every page. Links built with App.URL, c.URL and c.Asset include the
+// prefix, as do the pages written by GenerateStatic; hand-written hrefs
+// and Redirect URLs are used as they are.
+//
+// Requests without the prefix are routed unchanged, so the app also
+// works behind http.StripPrefix.
+func (a *App) BasePath(prefix string) {
+	a.base = strings.TrimSuffix(cleanPrefix(prefix), "/") // "" at the root
+	a.sessions.base = a.base
+}
+
+// trimBase removes the base path from p. ok is false if p is not under
+// the base path.
+func (a *App) trimBase(p string) (string, bool) {
+	if a.base == "" {
+		return p, false
+	}
+	rest, ok := strings.CutPrefix(p, a.base)
+	switch {
+	case !ok || rest != "" && rest[0] != '/':
+		return p, false
+	case rest == "":
+		return "/", true
+	}
+	return rest, true
+}
+
+// stripBase returns r with the base path removed from its URL.
+func (a *App) stripBase(r *http.Request) *http.Request {
+	p, ok := a.trimBase(r.URL.Path)
+	if !ok {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// pagePath removes the base path from the page path a client connects for.
+func (a *App) pagePath(p string) string {
+	p, _ = a.trimBase(p)
+	return p
+}
+
+// clientConfig is rendered into every live page for the client runtime.
+type clientConfig struct {
+	Base string `json:"base"` // Base path, "" at the root
+	WS   string `json:"ws"`   // WebSocket endpoint
+}
+
+// writeConfig writes the client config for base as a JSON script tag.
+// json.Marshal escapes "<", so it cannot close the tag.
+func writeConfig(w io.Writer, base string) error {
+	data, err := json.Marshal(clientConfig{Base: base, WS: base + "/ws"})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, `<script id="forge-config" type="application/json">`+string(data)+"</script>\n")
+	return err
+}
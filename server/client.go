@@ -0,0 +1,57 @@
+package server
+
+import "io"
+
+// Client selects the browser runtime that applies patches and forwards events.
+type Client int
+
+const (
+	// ClientWASM is the TinyGo WebAssembly client (server/wasm). It is the
+	// default, and the only client that runs client islands (see UseWASM).
+	ClientWASM Client = iota
+	// ClientJS is the hand-written JavaScript client (server/client/forge.js).
+	// It implements the same protocol and is much smaller to download.
+	ClientJS
+)
+
+// UseClient selects the client runtime served with every page.
+//
+//	app := forge.New()
+//	app.UseClient(forge.ClientJS)
+func (a *App) UseClient(c Client) { a.client = c }
+
+// UseWASM serves bundle instead of the built-in WASM client and selects
+// ClientWASM. bundle is a TinyGo build of a main package that calls
+// wasmclient.Run; build one to run client islands in the browser:
+//
+//	//go:embed app.wasm
+//	var bundle []byte
+//
+//	app.UseWASM(bundle)
+//
+// The bundle is served from a content-hashed URL like the built-in one.
+func (a *App) UseWASM(bundle []byte) {
+	a.client = ClientWASM
+	a.wasm = newAsset("app.wasm", "application/wasm", bundle, nil, nil)
+}
+
+// writeClient writes the client config and the script tags that load
+// and start client c from under base. The runtime is loaded from
+// content-hashed URLs, so browsers fetch it once per deploy. A nil wasm
+// selects the built-in WASM bundle.
+func writeClient(w io.Writer, c Client, wasm *asset, base string) error {
+	if err := writeConfig(w, base); err != nil {
+		return err
+	}
+	assets := runtimeAssets()
+	if c == ClientJS {
+		_, err := io.WriteString(w, `<script src="`+base+assets.js.url+`"></script>`)
+		return err
+	}
+	if wasm == nil {
+		wasm = assets.wasm
+	}
+	_, err := io.WriteString(w, `<script src="`+base+assets.wasmExec.url+`"></script>
+<script>const go=new Go();WebAssembly.instantiateStreaming(fetch("`+base+wasm.url+`"),go.importObject).then(r=>go.run(r.instance));</script>`)
+	return err
+}
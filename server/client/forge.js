@@ -0,0 +1,627 @@
+// Forge JavaScript client. Implements the same protocol as the wasm
+// client in server/wasmclient; keep the two in sync and run the
+// conformance suite (/_forge/conformance on the dev server) after changes.
+(function () {
+  "use strict";
+
+  var PROTOCOL_BINARY = "forge.v1.bin";
+  var PROTOCOL_JSON = "forge.v1.json";
+  var PATCH_TYPES = [null, "replace", "attrs", "text", "insert", "remove", "move"];
+  var UPLOAD_CHUNK_SIZE = 64 << 10;
+  var NS_SVG = "http://www.w3.org/2000/svg";
+  var NS_MATHML = "http://www.w3.org/1998/Math/MathML";
+  // Boolean attributes whose live DOM property can diverge from the
+  // attribute after user interaction.
+  var BOOL_PROPS = { checked: true, selected: true };
+
+  var ws = null;
+  var sessionID = "";
+  var internTable = [];
+  var uploadCounter = 0;
+  var decoder = new TextDecoder();
+
+  // Events
+
+  function setupEvents(doc) {
+    doc.addEventListener("click", function (evt) {
+      var el = closest(evt.target, "[data-forge-click]");
+      if (el) {
+        if (el.type !== "checkbox") evt.preventDefault();
+        send(el.dataset.forgeClick, "");
+      }
+    });
+
+    doc.addEventListener("input", function (evt) {
+      var id = evt.target.dataset && evt.target.dataset.forgeInput;
+      if (id !== undefined) send(id, evt.target.value);
+    });
+
+    doc.addEventListener("keydown", function (evt) {
+      if (evt.key !== "Enter") return;
+      var id = evt.target.dataset && evt.target.dataset.forgeKeydown;
+      if (id !== undefined) {
+        evt.preventDefault();
+        send(id, evt.target.value);
+      }
+    });
+
+    doc.addEventListener("change", function (evt) {
+      var target = evt.target;
+      if (!target.dataset) return;
+      if (target.dataset.forgeUpload !== undefined) uploadFiles(target.dataset.forgeUpload, target);
+      var id = target.dataset.forgeChange;
+      if (id !== undefined) {
+        var val = target.value;
+        if (target.type === "checkbox") val = target.checked ? "true" : "false";
+        send(id, val);
+      }
+    });
+
+    // Scroll events (debounced)
+    var scrollTimer;
+    doc.addEventListener("scroll", function (evt) {
+      var target = evt.target;
+      var id = target.dataset && target.dataset.forgeScroll;
+      if (id === undefined) return;
+      clearTimeout(scrollTimer);
+      scrollTimer = setTimeout(function () {
+        sendJSON({ type: "scroll", id: id, scrollTop: Math.round(target.scrollTop) });
+      }, 100);
+    }, true);
+
+    // Drag and drop
+    var dragID = "";
+    doc.addEventListener("dragstart", function (evt) {
+      var id = evt.target.dataset && evt.target.dataset.forgeDrag;
+      if (id !== undefined) dragID = id;
+    });
+    doc.addEventListener("dragover", function (evt) {
+      if (closest(evt.target, "[data-forge-dropzone]")) evt.preventDefault();
+    });
+    doc.addEventListener("drop", function (evt) {
+      evt.preventDefault();
+      var target = closest(evt.target, "[data-forge-dropzone]");
+      if (target) {
+        var id = target.dataset.forgeDrop;
+        if (id !== undefined && dragID !== "") {
+          sendJSON({ type: "drop", id: id, dragId: dragID });
+          dragID = "";
+        }
+      }
+    });
+  }
+
+  function closest(el, selector) {
+    return el && el.closest ? el.closest(selector) : null;
+  }
+
+  // Connection
+
+  // config is rendered by the server; ws moves with the app's base path.
+  var config = readConfig();
+
+  function readConfig() {
+    var el = document.getElementById("forge-config");
+    try {
+      return (el && JSON.parse(el.textContent)) || {};
+    } catch (e) {
+      return {};
+    }
+  }
+
+  function connect() {
+    var loc = window.location;
+    var url = (loc.protocol === "https:" ? "wss:" : "ws:") + "//" + loc.host + (config.ws || "/ws") +
+      "?path=" + encodeURIComponent(loc.pathname) +
+      "&query=" + encodeURIComponent(loc.search);
+    if (sessionID) url += "&session=" + sessionID;
+
+    ws = new WebSocket(url, [PROTOCOL_BINARY, PROTOCOL_JSON]);
+    ws.binaryType = "arraybuffer";
+    internTable = [];
+
+    ws.onmessage = function (evt) {
+      if (typeof evt.data !== "string") {
+        var patches = decodeFrame(new Uint8Array(evt.data));
+        if (patches) {
+          applyPatches(patches);
+        } else {
+          // The server has committed strings this client lacks; a new
+          // connection starts both tables afresh.
+          ws.close();
+        }
+        return;
+      }
+      var msg;
+      try {
+        msg = JSON.parse(evt.data);
+      } catch (e) {
+        return;
+      }
+      if (msg.type === "session") {
+        sessionID = msg.id;
+      } else if (msg.type === "patch") {
+        applyPatches(msg.patches || []);
+      } else if (msg.type === "download") {
+        download(msg.url, msg.filename);
+      } else if (msg.type === "reload") {
+        window.location.reload();
+      } else if (msg.type === "redirect") {
+        window.location.assign(msg.url);
+      } else if (msg.type === "url") {
+        history.replaceState(history.state, "", loc.pathname + msg.url + loc.hash);
+      }
+    };
+
+    ws.onclose = function () {
+      // Reconnect after 1 second
+      setTimeout(connect, 1000);
+    };
+  }
+
+  function send(id, value) {
+    if (/^island-[^:]*:/.test(id)) {
+      // Island handlers run only in a WASM bundle with islands enabled.
+      console.error("forge: client islands are inert with forge.js; serve a WASM bundle that calls wasmclient.EnableIslands (see docs/islands.md)");
+      return;
+    }
+    sendJSON({ type: "event", id: id, value: value });
+  }
+
+  function sendJSON(msg) {
+    if (ws && ws.readyState === 1) ws.send(JSON.stringify(msg));
+  }
+
+  // Binary frames (see server/protocol.go). Unknown versions and
+  // malformed frames yield null. Strings a frame adds to the intern table
+  // are kept only if the whole frame decodes, as on the server.
+
+  function decodeFrame(b) {
+    var pos = 0;
+    var bad = false;
+    var added = [];
+
+    function byte() {
+      if (pos >= b.length) {
+        bad = true;
+        return 0;
+      }
+      return b[pos++];
+    }
+    function uvarint() {
+      var x = 0;
+      for (var mul = 1; mul < 0x20000000000000; mul *= 128) {
+        var c = byte();
+        x += (c & 0x7f) * mul;
+        if (c < 0x80) return x;
+      }
+      bad = true;
+      return 0;
+    }
+    function lit() {
+      var n = uvarint();
+      if (pos + n > b.length) {
+        bad = true;
+        return "";
+      }
+      var s = decoder.decode(b.subarray(pos, pos + n));
+      pos += n;
+      return s;
+    }
+    function str() {
+      var tag = uvarint();
+      if (tag === 0) {
+        var s = lit();
+        added.push(s);
+        return s;
+      }
+      if (tag === 1) return lit();
+      var i = tag - 2;
+      if (i < internTable.length) return internTable[i];
+      if (i - internTable.length < added.length) return added[i - internTable.length];
+      bad = true;
+      return "";
+    }
+
+    if (byte() !== 1) return null;
+    var n = uvarint();
+    var patches = [];
+    for (var i = 0; i < n && !bad; i++) {
+      var type = PATCH_TYPES[byte()];
+      if (!type) return null;
+      var p = { type: type, id: str() };
+      switch (type) {
+        case "replace":
+          p.html = lit();
+          break;
+        case "attrs":
+          p.attrs = {};
+          for (var count = uvarint(), j = 0; j < count && !bad; j++) {
+            var k = str();
+            p.attrs[k] = lit();
+          }
+          p.unset = [];
+          for (count = uvarint(), j = 0; j < count && !bad; j++) p.unset.push(str());
+          break;
+        case "text":
+          p.text = lit();
+          break;
+        case "insert":
+          p.parent = str();
+          p.before = str();
+          p.html = lit();
+          break;
+        case "move":
+          p.parent = str();
+          p.before = str();
+          break;
+      }
+      patches.push(p);
+    }
+    if (bad) return null;
+    internTable = internTable.concat(added);
+    return patches;
+  }
+
+  // Downloads and uploads
+
+  // download makes the browser fetch a one-time download URL.
+  function download(url, filename) {
+    var a = document.createElement("a");
+    a.href = url;
+    a.download = filename;
+    a.style.display = "none";
+    document.body.appendChild(a);
+    a.click();
+    a.remove();
+  }
+
+  // uploadFiles streams every selected file of a file input to the server.
+  function uploadFiles(id, input) {
+    for (var i = 0; i < input.files.length; i++) {
+      uploadCounter++;
+      uploadFile(id, "u" + uploadCounter, input.files[i]);
+    }
+    input.value = "";
+  }
+
+  // uploadFile sends upload_start, then one upload_chunk per slice read
+  // from the file, then upload_end. Chunks are read sequentially so the
+  // server receives them in order.
+  function uploadFile(id, uploadID, file) {
+    sendJSON({ type: "upload_start", id: id, upload: uploadID, name: file.name, size: file.size, mime: file.type });
+    function next(offset) {
+      if (offset >= file.size) {
+        sendJSON({ type: "upload_end", upload: uploadID });
+        return;
+      }
+      var end = Math.min(offset + UPLOAD_CHUNK_SIZE, file.size);
+      file.slice(offset, end).arrayBuffer().then(function (buf) {
+        sendJSON({ type: "upload_chunk", upload: uploadID, data: base64(new Uint8Array(buf)) });
+        next(end);
+      });
+    }
+    next(0);
+  }
+
+  // base64 encodes bytes as encoding/json expects for []byte.
+  function base64(bytes) {
+    var s = "";
+    for (var i = 0; i < bytes.length; i += 8192) {
+      s += String.fromCharCode.apply(null, bytes.subarray(i, i + 8192));
+    }
+    return btoa(s);
+  }
+
+  // Patches
+
+  function applyPatches(patches) {
+    for (var i = 0; i < patches.length; i++) applyPatch(patches[i]);
+  }
+
+  function applyPatch(p) {
+    var el = byID(p.id);
+    var parent, marker;
+
+    switch (p.type) {
+      case "replace":
+        if (el) {
+          replaceNode(el, p.html);
+        } else if ((marker = textMarker(p.id))) {
+          parent = marker.parentNode;
+          parent.insertBefore(parseHTML(p.html, parent), marker);
+          removeMarkedText(marker);
+        }
+        break;
+      case "attrs":
+        if (!el) break;
+        for (var k in p.attrs || {}) {
+          el.setAttribute(k, p.attrs[k]);
+          if (BOOL_PROPS[k]) el[k] = true;
+        }
+        (p.unset || []).forEach(function (k) {
+          el.removeAttribute(k);
+          if (BOOL_PROPS[k]) el[k] = false;
+        });
+        break;
+      case "text":
+        // Text with siblings follows a <!--id--> marker; a lone text
+        // node is addressed through its parent element.
+        if ((marker = textMarker(p.id))) {
+          setMarkedText(marker, p.text || "");
+        } else if ((parent = domParent(p.id))) {
+          parent.textContent = p.text || "";
+        }
+        break;
+      case "remove":
+        if (el) {
+          el.remove();
+        } else if ((marker = textMarker(p.id))) {
+          removeMarkedText(marker);
+        }
+        break;
+      case "insert":
+        parent = patchParent(p);
+        parent.insertBefore(parseHTML(p.html, parent), childByID(parent, p.before));
+        break;
+      case "move":
+        parent = patchParent(p);
+        if (el) parent.insertBefore(el, childByID(parent, p.before));
+        break;
+    }
+  }
+
+  function byID(id) {
+    return document.querySelector(idSelector(id));
+  }
+
+  // idSelector returns a selector matching data-forge-id, escaping the ID
+  // so keys with quotes or backslashes cannot break the selector.
+  function idSelector(id) {
+    return '[data-forge-id="' + CSS.escape(id) + '"]';
+  }
+
+  // parseHTML parses patch HTML into a DocumentFragment as children of
+  // parent. Inside SVG or MathML the markup is parsed within an <svg> or
+  // <math> wrapper, so <path> or <mi> get their namespace.
+  function parseHTML(html, parent) {
+    var tpl = document.createElement("template");
+    var wrapper = "";
+    if (parent && parent.localName !== "foreignObject") {
+      if (parent.namespaceURI === NS_SVG) wrapper = "svg";
+      else if (parent.namespaceURI === NS_MATHML) wrapper = "math";
+    }
+    if (!wrapper) {
+      tpl.innerHTML = html;
+      return tpl.content;
+    }
+    tpl.innerHTML = "<" + wrapper + ">" + html + "</" + wrapper + ">";
+    var root = tpl.content.firstChild;
+    var frag = document.createDocumentFragment();
+    while (root.firstChild) frag.appendChild(root.firstChild);
+    return frag;
+  }
+
+  // replaceNode morphs target into html when it is a single element,
+  // otherwise replaces it with the parsed nodes.
+  function replaceNode(target, html) {
+    var frag = parseHTML(html, target.parentNode);
+    if (frag.childNodes.length === 1 && frag.firstChild.nodeType === 1) {
+      morph(target, frag.firstChild);
+      return;
+    }
+    target.replaceWith(frag);
+  }
+
+  // markerText escapes an ID the way the renderer writes it in a marker
+  // comment.
+  function markerText(id) {
+    return id.replace(/%/g, "%25").replace(/</g, "%3C").replace(/>/g, "%3E");
+  }
+
+  // textMarker finds the <!--id--> comment preceding a text node.
+  function textMarker(id) {
+    // Text of a root fragment sits directly in the body.
+    var parent = domParent(id) || document.body;
+    var data = markerText(id);
+    var kids = parent.childNodes;
+    for (var i = 0; i < kids.length; i++) {
+      if (kids[i].nodeType === 8 && kids[i].data === data) return kids[i];
+    }
+    return null;
+  }
+
+  // setMarkedText sets the text following a marker, creating the text
+  // node if it was rendered empty.
+  function setMarkedText(marker, text) {
+    var next = marker.nextSibling;
+    if (next && next.nodeType === 3) {
+      next.data = text;
+      return;
+    }
+    marker.parentNode.insertBefore(document.createTextNode(text), next);
+  }
+
+  // removeMarkedText removes a marker and the text node it precedes.
+  function removeMarkedText(marker) {
+    var next = marker.nextSibling;
+    if (next && next.nodeType === 3) next.remove();
+    marker.remove();
+  }
+
+  // patchParent finds the parent element of an insert or move patch.
+  function patchParent(p) {
+    var parent = p.parent ? closestByID(p.parent) : domParent(p.id);
+    // Children of a root fragment sit directly in the body.
+    return parent || document.body;
+  }
+
+  // childByID returns the direct child of parent with the given
+  // data-forge-id, or the marker comment of the text with that ID.
+  // Returns null (append position) if id is empty or not found.
+  function childByID(parent, id) {
+    if (!id) return null;
+    return parent.querySelector(":scope > " + idSelector(id)) || textMarker(id);
+  }
+
+  // domParent finds the element containing the node at path, skipping
+  // fragment slots that have no element of their own.
+  function domParent(path) {
+    return closestByID(parentPath(path));
+  }
+
+  // closestByID returns the element with the given data-forge-id, or that
+  // of its nearest ancestor path. Returns null if none is rendered.
+  function closestByID(path) {
+    for (;;) {
+      var el = byID(path);
+      if (el) return el;
+      var i = path.lastIndexOf(".");
+      if (i < 0) return null;
+      path = path.slice(0, i);
+    }
+  }
+
+  function parentPath(path) {
+    var i = path.lastIndexOf(".");
+    return i < 0 ? "0" : path.slice(0, i);
+  }
+
+  // morph updates target in place to match the parsed element src.
+  function morph(target, src) {
+    // Different tag or namespace - replace entirely
+    if (target.tagName !== src.tagName || target.namespaceURI !== src.namespaceURI) {
+      target.replaceWith(src);
+      return;
+    }
+    syncAttrs(target, src);
+    morphChildren(target, src);
+  }
+
+  function syncAttrs(target, src) {
+    var i, attr;
+    for (i = target.attributes.length - 1; i >= 0; i--) {
+      attr = target.attributes[i];
+      if (!src.hasAttribute(attr.name)) target.removeAttribute(attr.name);
+    }
+    for (i = 0; i < src.attributes.length; i++) {
+      attr = src.attributes[i];
+      if (target.getAttribute(attr.name) !== attr.value) target.setAttribute(attr.name, attr.value);
+    }
+
+    // Handle input properties
+    if (target.tagName === "INPUT") {
+      target.checked = src.hasAttribute("checked");
+      if (src.hasAttribute("value")) target.value = src.getAttribute("value");
+    }
+  }
+
+  function morphChildren(target, src) {
+    var tKids = target.childNodes;
+    var sKids = src.childNodes;
+    var max = Math.max(tKids.length, sKids.length);
+
+    for (var i = 0; i < max; i++) {
+      var t = tKids[i];
+      var s = sKids[i];
+      if (!s) {
+        // Removing shifts the remaining target children down.
+        while (tKids.length > sKids.length) tKids[tKids.length - 1].remove();
+        return;
+      }
+      if (!t) {
+        target.appendChild(s.cloneNode(true));
+        continue;
+      }
+      if (t.nodeType !== s.nodeType) {
+        t.replaceWith(s.cloneNode(true));
+        continue;
+      }
+      if (t.nodeType === 3 || t.nodeType === 8) {
+        // Text node or marker comment
+        if (t.data !== s.data) t.data = s.data;
+        continue;
+      }
+      if (t.nodeType === 1) morph(t, s.cloneNode(true));
+    }
+  }
+
+  // Lazy loading
+
+  function setupLazyLoading(doc) {
+    var opts = { threshold: 0.1 };
+    var observer = new IntersectionObserver(function (entries) {
+      entries.forEach(function (entry) {
+        if (!entry.isIntersecting) return;
+        var id = entry.target.dataset.forgeVisible;
+        if (id !== undefined) send(id, "");
+      });
+    }, opts);
+
+    doc.querySelectorAll("[data-forge-visible]").forEach(function (el) {
+      observer.observe(el);
+    });
+
+    // Observe new elements
+    new MutationObserver(function (mutations) {
+      mutations.forEach(function (m) {
+        m.addedNodes.forEach(function (node) {
+          if (node.nodeType !== 1) return;
+          if (node.dataset && node.dataset.forgeVisible !== undefined) observer.observe(node);
+          node.querySelectorAll("[data-forge-visible]").forEach(function (el) {
+            observer.observe(el);
+          });
+        });
+      });
+    }).observe(doc.body, { childList: true, subtree: true });
+
+    // Lazy images
+    var imgObserver = new IntersectionObserver(function (entries, obs) {
+      entries.forEach(function (entry) {
+        if (!entry.isIntersecting) return;
+        var img = entry.target;
+        if (img.dataset.src !== undefined) {
+          img.src = img.dataset.src;
+          img.classList.add("loaded");
+          obs.unobserve(img);
+        }
+      });
+    }, opts);
+    doc.querySelectorAll(".lazy-image").forEach(function (img) {
+      imgObserver.observe(img);
+    });
+  }
+
+  // Startup
+
+  // forgeClient is the hook used by the conformance suite.
+  window.forgeClient = {
+    name: "js",
+    apply: function (json) {
+      applyPatches(JSON.parse(json));
+    },
+    applyBinary: function (bytes) {
+      applyPatches(decodeFrame(bytes) || []);
+    },
+    reset: function () {
+      internTable = [];
+    },
+  };
+
+  function start() {
+    if (window.forgeTestMode) {
+      document.dispatchEvent(new Event("forge:ready"));
+      return;
+    }
+    setupEvents(document);
+    setupLazyLoading(document);
+    connect();
+    document.dispatchEvent(new Event("forge:ready"));
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", start);
+  } else {
+    start();
+  }
+})();
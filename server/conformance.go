@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/Shravanthh/forge/diff"
+	"github.com/Shravanthh/forge/render"
+	"github.com/Shravanthh/forge/ui"
+	"github.com/Shravanthh/forge/ui/svg"
+)
+
+// conformancePath serves the client conformance suite on the dev server.
+const conformancePath = "/_forge/conformance"
+
+// conformanceCase is one old/new tree pair. The suite renders Old,
+// applies the patches diff produces, and expects the DOM to equal New.
+type conformanceCase struct {
+	Name     string
+	Old, New ui.UI
+}
+
+// conformanceCases covers every patch type and the addressing rules
+// (keys, text markers, fragment paths, namespaces, escaped IDs).
+// Add a case here whenever the protocol or either client changes.
+var conformanceCases = []conformanceCase{
+	{"text-only update",
+		ui.Div(ui.P(ui.T("before"))),
+		ui.Div(ui.P(ui.T("after")))},
+	{"attrs set and unset",
+		ui.Div(ui.Button(ui.T("Go")).WithClass("a").Disabled(true).WithAttr("title", "x")),
+		ui.Div(ui.Button(ui.T("Go")).WithClass("b").WithAttr("aria-label", "go"))},
+	{"boolean attribute false",
+		ui.Div(ui.Input().WithAttr("type", "checkbox")),
+		ui.Div(ui.Input().WithAttr("type", "checkbox").Checked(true).WithAttr("disabled", "false"))},
+	{"replace tag",
+		ui.Div(ui.P(ui.T("x")), ui.Span(ui.T("y"))),
+		ui.Div(ui.H2(ui.T("x")), ui.Span(ui.T("y")))},
+	{"unkeyed append",
+		ui.Ul(ui.Li(ui.T("1"))),
+		ui.Ul(ui.Li(ui.T("1")), ui.Li(ui.T("2")), ui.Li(ui.T("3")))},
+	{"unkeyed truncate",
+		ui.Ul(ui.Li(ui.T("1")), ui.Li(ui.T("2")), ui.Li(ui.T("3"))),
+		ui.Ul(ui.Li(ui.T("1")))},
+	{"keyed reorder",
+		keyedList("a", "b", "c", "d", "e"),
+		keyedList("e", "b", "a", "d", "c")},
+	{"keyed insert and remove",
+		keyedList("a", "b", "c"),
+		keyedList("x", "a", "c", "y")},
+	{"keyed move with content change",
+		ui.Ul(ui.Li(ui.T("A")).WithID("k-a"), ui.Li(ui.T("B")).WithID("k-b")),
+		ui.Ul(ui.Li(ui.T("B2")).WithID("k-b"), ui.Li(ui.T("A")).WithID("k-a"))},
+	{"mixed content text",
+		ui.P(ui.T("Hello "), ui.El("b", ui.T("world")), ui.T("!")),
+		ui.P(ui.T("Bye "), ui.El("b", ui.T("world")), ui.T("?"))},
+	{"mixed content text insert and remove",
+		ui.P(ui.T("a"), ui.Br(), ui.T("b")),
+		ui.P(ui.Br(), ui.T("b"), ui.T("c"))},
+	{"text to element",
+		ui.Div(ui.T("a"), ui.Span(ui.T("b"))),
+		ui.Div(ui.El("em", ui.T("a")), ui.Span(ui.T("b")))},
+	{"fragment grows",
+		ui.Div(ui.P(ui.T("head")), ui.If(false, ui.P(ui.T("x"))), ui.P(ui.T("tail"))),
+		ui.Div(ui.P(ui.T("head")), ui.Fragment(ui.P(ui.T("x")), ui.T("y")), ui.P(ui.T("tail")))},
+	{"fragment shrinks",
+		ui.Div(ui.Fragment(ui.P(ui.T("x")), ui.T("y")), ui.P(ui.T("tail"))),
+		ui.Div(ui.Fragment(), ui.P(ui.T("tail")))},
+	{"svg insert and update",
+		ui.Div(svg.Svg("0 0 10 10", svg.Circle(5, 5, 2))),
+		ui.Div(svg.Svg("0 0 10 10", svg.Circle(5, 5, 3), svg.Path("M0 0L10 10"), svg.Text(1, 9, ui.T("t"))))},
+	{"svg replace",
+		ui.Div(svg.Svg("0 0 10 10", svg.Rect(0, 0, 5, 5))),
+		ui.Div(svg.Svg("0 0 10 10", svg.G(svg.Circle(1, 1, 1))))},
+	{"raw html",
+		ui.Div(ui.Raw{HTML: "<b>old</b>"}),
+		ui.Div(ui.Raw{HTML: "<i>new</i>"})},
+	{"escaped ids",
+		ui.Div(ui.P(ui.T(`a`), ui.Span(ui.T("s"))).WithID(`q"uo>te`)),
+		ui.Div(ui.P(ui.T(`b`), ui.Span(ui.T("s2"))).WithID(`q"uo>te`))},
+}
+
+func keyedList(keys ...string) ui.UI {
+	items := make([]ui.UI, len(keys))
+	for i, k := range keys {
+		items[i] = ui.Li(ui.T(k)).WithID("item-" + k)
+	}
+	return ui.Ul(items...)
+}
+
+// conformanceData is a case as sent to the browser.
+type conformanceData struct {
+	Name    string       `json:"name"`
+	Before  string       `json:"before"`
+	After   string       `json:"after"`
+	Patches []diff.Patch `json:"patches"`
+	Binary  []byte       `json:"binary"`
+}
+
+// ConformanceHandler serves a page that checks a client against the
+// protocol: each case renders a tree, applies the patches the server
+// would send (as JSON and as binary frames) and compares the DOM with
+// the rendered target tree. Select the client with ?client=js or
+// ?client=wasm. Results are shown on the page and stored in
+// window.forgeConformance for headless runners.
+//
+// The dev server mounts it at /_forge/conformance.
+func ConformanceHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ClientJS
+		if r.URL.Query().Get("client") == "wasm" {
+			client = ClientWASM
+		}
+
+		// One encoder for all cases, so later cases exercise interned strings.
+		enc := newBinaryEncoder()
+		cases := make([]conformanceData, len(conformanceCases))
+		for i, tc := range conformanceCases {
+			patches := diff.Diff(tc.Old, tc.New)
+			cases[i] = conformanceData{
+				Name:    tc.Name,
+				Before:  render.HTML(tc.Old),
+				After:   render.HTML(tc.New),
+				Patches: patches,
+				Binary:  append([]byte(nil), enc.encode(patches)...),
+			}
+			enc.commit()
+		}
+		data, err := json.Marshal(cases)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		io.WriteString(w, conformanceStart)
+		w.Write(data)
+		io.WriteString(w, ";\n</script>\n<script>"+conformanceHarness+"</script>\n")
+		writeClient(w, client, nil, "")
+		io.WriteString(w, "\n</body>\n</html>")
+	})
+}
+
+const conformanceStart = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>Forge client conformance</title>
+</head>
+<body>
+<h1>Forge client conformance</h1>
+<pre id="forge-conformance-results">running...</pre>
+<div id="forge-conformance-root"></div>
+<script>
+window.forgeTestMode = true;
+window.forgeCases = `
+
+// conformanceHarness runs every case in JSON and binary mode once the
+// client signals it is ready.
+const conformanceHarness = `
+document.addEventListener("forge:ready", function () {
+  var client = window.forgeClient;
+  var root = document.getElementById("forge-conformance-root");
+  var results = [];
+  client.reset();
+  ["json", "binary"].forEach(function (mode) {
+    window.forgeCases.forEach(function (tc) {
+      root.innerHTML = tc.before;
+      var error = "";
+      try {
+        if (mode === "json") {
+          client.apply(JSON.stringify(tc.patches || []));
+        } else {
+          var bin = atob(tc.binary), bytes = new Uint8Array(bin.length);
+          for (var i = 0; i < bin.length; i++) bytes[i] = bin.charCodeAt(i);
+          client.applyBinary(bytes);
+        }
+      } catch (e) {
+        error = String(e);
+      }
+      var want = document.createElement("div");
+      want.innerHTML = tc.after;
+      var ok = !error && root.childNodes.length === want.childNodes.length;
+      for (var j = 0; ok && j < want.childNodes.length; j++) {
+        ok = root.childNodes[j].isEqualNode(want.childNodes[j]);
+      }
+      results.push({ name: tc.name, mode: mode, ok: ok, error: error, got: ok ? "" : root.innerHTML, want: ok ? "" : tc.after });
+    });
+  });
+  root.innerHTML = "";
+  var failed = results.filter(function (r) { return !r.ok; });
+  window.forgeConformance = { client: client.name, passed: results.length - failed.length, failed: failed.length, results: results };
+  document.getElementById("forge-conformance-results").textContent =
+    client.name + ": " + (results.length - failed.length) + "/" + results.length + " passed\n\n" +
+    failed.map(function (r) {
+      return "FAIL " + r.name + " (" + r.mode + ")" + (r.error ? "\n  error: " + r.error : "") +
+        "\n  got:  " + r.got + "\n  want: " + r.want;
+    }).join("\n\n");
+});
+`
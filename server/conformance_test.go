@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// browsers are the executable names tried when FORGE_CHROME is unset.
+var browsers = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable",
+	"chrome", "chrome-headless-shell", "headless_shell",
+}
+
+// findBrowser returns the Chrome or Chromium binary to run the
+// conformance suite with: $FORGE_CHROME, or the first one on PATH.
+func findBrowser() string {
+	if path := os.Getenv("FORGE_CHROME"); path != "" {
+		return path
+	}
+	for _, name := range browsers {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// TestConformance runs the conformance page in headless Chrome for both
+// clients and fails on any case that does not reproduce the target DOM.
+// It is skipped when no browser is found; set FORGE_CHROME to point at one.
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+	browser := findBrowser()
+	if browser == "" {
+		t.Skip("no Chrome or Chromium found; set FORGE_CHROME to run the conformance suite")
+	}
+
+	srv := httptest.NewServer(NewDev(t.TempDir()))
+	defer srv.Close()
+
+	for _, client := range []string{"js", "wasm"} {
+		t.Run(client, func(t *testing.T) {
+			result, err := runConformance(browser, srv.URL+conformancePath+"?client="+client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Passed == 0 {
+				t.Fatalf("%s client ran no cases", result.Client)
+			}
+			for _, r := range result.Results {
+				if !r.OK {
+					t.Errorf("%s (%s): error %q\n got:  %s\n want: %s", r.Name, r.Mode, r.Error, r.Got, r.Want)
+				}
+			}
+		})
+	}
+}
+
+// conformanceResult mirrors window.forgeConformance.
+type conformanceResult struct {
+	Client  string `json:"client"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Results []struct {
+		Name  string `json:"name"`
+		Mode  string `json:"mode"`
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		Got   string `json:"got"`
+		Want  string `json:"want"`
+	} `json:"results"`
+}
+
+// awaitConformance resolves with window.forgeConformance as JSON once the
+// harness has stored it, or with "" if it has not after 20 seconds.
+const awaitConformance = `new Promise(function (resolve) {
+  var deadline = Date.now() + 20000;
+  (function poll() {
+    if (window.forgeConformance) resolve(JSON.stringify(window.forgeConformance));
+    else if (Date.now() > deadline) resolve("");
+    else setTimeout(poll, 20);
+  })();
+})`
+
+var devToolsURL = regexp.MustCompile(`DevTools listening on (ws://\S+)`)
+
+// runConformance opens url in a fresh headless browser and reads the
+// results over the DevTools protocol.
+func runConformance(browser, url string) (*conformanceResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", "forge-chrome")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, browser,
+		"--headless", "--no-sandbox", "--disable-gpu", "--no-first-run",
+		"--remote-debugging-port=0", "--user-data-dir="+dir, url)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	// The browser prints its DevTools endpoint once it is listening.
+	var endpoint string
+	lines := bufio.NewScanner(stderr)
+	for lines.Scan() {
+		if m := devToolsURL.FindStringSubmatch(lines.Text()); m != nil {
+			endpoint = m[1]
+			break
+		}
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s did not start a DevTools endpoint", browser)
+	}
+	go func() {
+		for lines.Scan() {
+		}
+	}()
+
+	page, err := pageTarget(ctx, endpoint, url)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, page, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+
+	// Errors logged by the page explain a client that never reports.
+	var errs []string
+	if err := conn.WriteJSON(map[string]any{"id": 1, "method": "Runtime.enable"}); err != nil {
+		return nil, err
+	}
+	// The tab may still be navigating to url, which destroys the context
+	// the first evaluation runs in; evaluate again until it settles.
+	for id := 2; ; id++ {
+		result, err := evaluate(conn, id, &errs)
+		if err == nil || !strings.Contains(err.Error(), "context was destroyed") {
+			if err != nil && len(errs) > 0 {
+				err = fmt.Errorf("%w\npage errors:\n%s", err, strings.Join(errs, "\n"))
+			}
+			return result, err
+		}
+	}
+}
+
+// evaluate waits for the results in the tab connected to conn, adding
+// the errors the page logs meanwhile to errs.
+func evaluate(conn *websocket.Conn, id int, errs *[]string) (*conformanceResult, error) {
+	err := conn.WriteJSON(map[string]any{
+		"id":     id,
+		"method": "Runtime.evaluate",
+		"params": map[string]any{"expression": awaitConformance, "awaitPromise": true, "returnByValue": true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var msg struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+			Params struct {
+				Type string `json:"type"`
+				Args []struct {
+					Value       any    `json:"value"`
+					Description string `json:"description"`
+				} `json:"args"`
+				ExceptionDetails struct {
+					Exception struct {
+						Description string `json:"description"`
+					} `json:"exception"`
+				} `json:"exceptionDetails"`
+			} `json:"params"`
+			Result struct {
+				Result struct {
+					Value string `json:"value"`
+				} `json:"result"`
+				ExceptionDetails *struct {
+					Text string `json:"text"`
+				} `json:"exceptionDetails"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return nil, fmt.Errorf("waiting for results: %w", err)
+		}
+		switch msg.Method {
+		case "Runtime.exceptionThrown":
+			*errs = append(*errs, msg.Params.ExceptionDetails.Exception.Description)
+		case "Runtime.consoleAPICalled":
+			if msg.Params.Type == "error" {
+				var args []string
+				for _, a := range msg.Params.Args {
+					if a.Value != nil {
+						args = append(args, fmt.Sprint(a.Value))
+					} else {
+						args = append(args, a.Description)
+					}
+				}
+				*errs = append(*errs, strings.Join(args, " "))
+			}
+		}
+		if msg.ID != id {
+			continue
+		}
+		if msg.Error != nil {
+			return nil, fmt.Errorf("Runtime.evaluate: %s", msg.Error.Message)
+		}
+		if e := msg.Result.ExceptionDetails; e != nil {
+			return nil, fmt.Errorf("Runtime.evaluate: %s", e.Text)
+		}
+		if msg.Result.Result.Value == "" {
+			return nil, fmt.Errorf("no results: the client never signalled forge:ready")
+		}
+		var result conformanceResult
+		if err := json.Unmarshal([]byte(msg.Result.Result.Value), &result); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+}
+
+// pageTarget returns the DevTools WebSocket URL of the tab showing url.
+func pageTarget(ctx context.Context, endpoint, url string) (string, error) {
+	host := strings.TrimPrefix(endpoint, "ws://")
+	host = host[:strings.IndexByte(host, '/')]
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://"+host+"/json/list", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", err
+		}
+		var targets []struct {
+			Type                 string `json:"type"`
+			URL                  string `json:"url"`
+			WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&targets)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		for _, t := range targets {
+			if t.Type == "page" && t.URL == url {
+				return t.WebSocketDebuggerURL, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no browser tab for %s", url)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
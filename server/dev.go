@@ -7,24 +7,22 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
-	devClients   = make(map[*websocket.Conn]bool)
+	devClients   = make(map[*outbox]bool)
 	devClientsMu sync.RWMutex
 )
 
-func registerDevClient(conn *websocket.Conn) {
+func registerDevClient(o *outbox) {
 	devClientsMu.Lock()
-	devClients[conn] = true
+	devClients[o] = true
 	devClientsMu.Unlock()
 }
 
-func unregisterDevClient(conn *websocket.Conn) {
+func unregisterDevClient(o *outbox) {
 	devClientsMu.Lock()
-	delete(devClients, conn)
+	delete(devClients, o)
 	devClientsMu.Unlock()
 }
 
@@ -46,12 +44,24 @@ func NewDev(watchDir string) *DevServer {
 
 // Run starts dev server with file watching.
 func (d *DevServer) Run(addr string) error {
+	if err := d.router.CheckNames(); err != nil {
+		return err
+	}
 	go d.watchFiles()
 	go d.broadcastReloads()
 	log.Printf("Forge DEV running at http://localhost%s (hot reload enabled)\n", addr)
 	return http.ListenAndServe(addr, d)
 }
 
+// ServeHTTP adds the client conformance suite to the app's routes.
+func (d *DevServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == conformancePath {
+		ConformanceHandler().ServeHTTP(w, r)
+		return
+	}
+	d.App.ServeHTTP(w, r)
+}
+
 func (d *DevServer) watchFiles() {
 	lastMod := time.Now()
 	for {
@@ -81,8 +91,8 @@ func (d *DevServer) watchFiles() {
 func (d *DevServer) broadcastReloads() {
 	for range d.reloadChan {
 		devClientsMu.RLock()
-		for conn := range devClients {
-			conn.WriteJSON(map[string]string{"type": "reload"})
+		for o := range devClients {
+			o.send(map[string]string{"type": "reload"})
 		}
 		devClientsMu.RUnlock()
 	}
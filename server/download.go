@@ -0,0 +1,132 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// downloadPrefix is the URL prefix for one-time download links.
+const downloadPrefix = "/_forge/download/"
+
+// downloadTTL is how long a download link stays valid if never fetched.
+const downloadTTL = 5 * time.Minute
+
+type pendingDownload struct {
+	ctx.Download
+	expires time.Time
+}
+
+// downloadRegistry holds downloads waiting to be fetched by the browser.
+type downloadRegistry struct {
+	mu    sync.Mutex
+	items map[string]*pendingDownload
+	purge *time.Timer // Pending while items is not empty
+}
+
+func newDownloadRegistry() *downloadRegistry {
+	return &downloadRegistry{items: make(map[string]*pendingDownload)}
+}
+
+// add registers a download and returns its URL. The URL carries nothing
+// but a random 128-bit token, so it does not reveal the session ID.
+func (d *downloadRegistry) add(dl ctx.Download) string {
+	var b [16]byte
+	rand.Read(b[:])
+	token := hex.EncodeToString(b[:])
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.purgeLocked(time.Now())
+	d.items[token] = &pendingDownload{Download: dl, expires: time.Now().Add(downloadTTL)}
+	if d.purge == nil {
+		d.purge = time.AfterFunc(downloadTTL, d.purgeExpired)
+	}
+	return downloadPrefix + token
+}
+
+// take removes and returns a download if it exists and is unexpired.
+func (d *downloadRegistry) take(token string) *pendingDownload {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.purgeLocked(time.Now())
+	dl, ok := d.items[token]
+	if !ok {
+		return nil
+	}
+	delete(d.items, token)
+	return dl
+}
+
+// purgeExpired runs on a timer so that downloads never fetched release
+// their content, rescheduling itself while any remain.
+func (d *downloadRegistry) purgeExpired() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.purgeLocked(time.Now())
+	d.purge = nil
+	var next time.Time
+	for _, dl := range d.items {
+		if next.IsZero() || dl.expires.Before(next) {
+			next = dl.expires
+		}
+	}
+	if !next.IsZero() {
+		d.purge = time.AfterFunc(time.Until(next)+time.Second, d.purgeExpired)
+	}
+}
+
+// purgeLocked closes and removes the downloads expired at now. The caller
+// must hold d.mu.
+func (d *downloadRegistry) purgeLocked(now time.Time) {
+	for token, dl := range d.items {
+		if now.After(dl.expires) {
+			closeContent(dl.Content)
+			delete(d.items, token)
+		}
+	}
+}
+
+func (d *downloadRegistry) serve(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, downloadPrefix)
+	dl := d.take(token)
+	if dl == nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer closeContent(dl.Content)
+
+	contentType := dl.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": SanitizeFilename(dl.Filename),
+	}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	io.Copy(w, dl.Content)
+}
+
+// sendDownloads registers the downloads requested by handlers and tells
+// the client to fetch them. The caller must hold s.mu.
+func (sm *SessionManager) sendDownloads(s *Session) {
+	for _, dl := range s.Context.TakeDownloads() {
+		url := sm.base + sm.downloads.add(dl)
+		s.out.send(Response{Type: "download", URL: url, Filename: SanitizeFilename(dl.Filename)})
+	}
+}
+
+func closeContent(r io.Reader) {
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
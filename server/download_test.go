@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+func TestDownloadRegistry(t *testing.T) {
+	d := newDownloadRegistry()
+	url := d.add(ctx.Download{Filename: "report.csv", ContentType: "text/csv", Content: strings.NewReader("a,b\n")})
+	if !strings.HasPrefix(url, downloadPrefix) || strings.Contains(url, "?") {
+		t.Fatalf("download URL = %q; want %s<token> without a query", url, downloadPrefix)
+	}
+
+	fetch := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		d.serve(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+	w := fetch()
+	if w.Code != http.StatusOK || w.Body.String() != "a,b\n" {
+		t.Errorf("first fetch = %d %q; want 200 %q", w.Code, w.Body.String(), "a,b\n")
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename=report.csv` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if w := fetch(); w.Code != http.StatusNotFound {
+		t.Errorf("second fetch = %d; want 404", w.Code)
+	}
+}
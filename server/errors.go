@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Shravanthh/forge/ctx"
+	"github.com/Shravanthh/forge/ui"
+)
+
+// ErrNotFound makes a guard answer 404 with the not-found page, e.g.
+// for a record that does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrorPageFunc renders the page shown for err.
+type ErrorPageFunc func(c *ctx.Context, err error) ui.UI
+
+// NotFound sets the page rendered, with status 404, for paths no route
+// matches. It is wrapped in the layouts covering the requested path and
+// runs live like any other page, except when a guard answers ErrNotFound:
+// then it is sent without the client runtime, since the session would
+// be refused again. GenerateStatic writes it to 404.html.
+//
+//	app.NotFound(func(c *forge.Context) ui.UI {
+//	    return ui.Div(ui.H1(ui.T("Page not found")), ui.A(ui.T("Home")).WithAttr("href", "/"))
+//	})
+func (a *App) NotFound(page PageFunc) { a.notFound = page }
+
+// ErrorPage sets the page rendered when a page panics (500) or a guard
+// refuses it (403). It is wrapped in the layouts covering the requested
+// path and sent without the client runtime, so it is not live. Without
+// one, a plain-text error is sent.
+//
+//	app.ErrorPage(func(c *forge.Context, err error) ui.UI {
+//	    return ui.Div(ui.H1(ui.T("Something went wrong")))
+//	})
+func (a *App) ErrorPage(page ErrorPageFunc) { a.errorPage = page }
+
+// notFoundPage returns the not-found page wrapped in the layouts
+// covering path, or nil if none is set.
+func (a *App) notFoundPage(path string) PageFunc {
+	if a.notFound == nil {
+		return nil
+	}
+	return withLayouts(a.notFound, a.router.GetLayouts(path))
+}
+
+// renderNotFound answers 404 with the not-found page, live unless it
+// stands in for a page a guard refused.
+func (a *App) renderNotFound(w http.ResponseWriter, r *http.Request, c *ctx.Context, live bool) {
+	page := a.notFoundPage(r.URL.Path)
+	if page == nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, err := renderSafe(page, c)
+	if err != nil {
+		a.renderError(w, r, c, http.StatusInternalServerError, err)
+		return
+	}
+	if live {
+		a.writePage(w, http.StatusNotFound, content)
+		return
+	}
+	// The page's WebSocket would be refused and reload it, forever.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	writeHTMLStatic(w, content)
+}
+
+// renderError answers status with the error page for err. If the error
+// page itself fails, a plain-text error is sent.
+func (a *App) renderError(w http.ResponseWriter, r *http.Request, c *ctx.Context, status int, err error) {
+	if a.errorPage == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	page := withLayouts(func(c *ctx.Context) ui.UI { return a.errorPage(c, err) }, a.router.GetLayouts(r.URL.Path))
+	content, perr := renderSafe(page, c)
+	if perr != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	// Without the client runtime: a live session would only fail again.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	writeHTMLStatic(w, content)
+}
+
+// renderSafe renders page, turning a panic into an error.
+func renderSafe(page PageFunc, c *ctx.Context) (content ui.UI, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("page panic: %v", r)
+			err = fmt.Errorf("page panic: %v", r)
+		}
+	}()
+	ui.ResetEventCounter(c)
+	return page(c), nil
+}
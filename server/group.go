@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// Guard decides whether a page may render for the current session. It
+// returns nil to allow it, a Redirect to send the browser elsewhere,
+// ErrNotFound to answer 404, or any other error (such as ErrForbidden) to
+// refuse it with 403.
+//
+// Guards run before the initial HTTP render, when a session connects
+// and before every re-render over the WebSocket, so revoking a role in
+// a handler takes effect immediately. Group endpoints run them before
+// every request.
+type Guard func(c *ctx.Context) error
+
+// ErrForbidden refuses a page with 403 Forbidden.
+var ErrForbidden = errors.New("forbidden")
+
+// RedirectError is returned by Redirect.
+type RedirectError struct {
+	URL string
+}
+
+func (e *RedirectError) Error() string { return "redirect to " + e.URL }
+
+// Redirect returns a guard error that sends the browser to url.
+//
+//	func LoggedIn(c *ctx.Context) error {
+//	    if c.String("user") == "" {
+//	        return server.Redirect("/login")
+//	    }
+//	    return nil
+//	}
+func Redirect(url string) error { return &RedirectError{URL: url} }
+
+// RequireRole allows sessions that have any of roles (see
+// ctx.Context.HasRole) and refuses others with ErrForbidden.
+func RequireRole(roles ...string) Guard {
+	return func(c *ctx.Context) error {
+		for _, role := range roles {
+			if c.HasRole(role) {
+				return nil
+			}
+		}
+		return ErrForbidden
+	}
+}
+
+type rolesKey struct{}
+
+// WithRoles returns r carrying the roles of the authenticated user. Call
+// it from authentication middleware; the roles are copied into the
+// Context of pages rendered for r, and of sessions it upgrades to.
+//
+//	func Auth(next http.Handler) http.Handler {
+//	    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//	        if user := lookupUser(r); user != nil {
+//	            r = server.WithRoles(r, user.Roles...)
+//	        }
+//	        next.ServeHTTP(w, r)
+//	    })
+//	}
+func WithRoles(r *http.Request, roles ...string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), rolesKey{}, roles))
+}
+
+func requestRoles(r *http.Request) []string {
+	roles, _ := r.Context().Value(rolesKey{}).([]string)
+	return roles
+}
+
+// Group registers routes under a common prefix with their own layouts,
+// middleware and guards.
+type Group struct {
+	app        *App
+	parent     *Group
+	prefix     string
+	middleware []Middleware
+	guards     []Guard
+}
+
+// Group registers the routes added in fn under prefix.
+//
+//	app.Group("/admin", func(g *forge.Group) {
+//	    g.Use(AuthMiddleware)
+//	    g.Guard(server.RequireRole("admin"))
+//	    g.Layout("/", AdminLayout)
+//	    g.Route("/", Dashboard)       // /admin
+//	    g.Route("/users", UsersPage)  // /admin/users
+//	})
+func (a *App) Group(prefix string, fn func(g *Group)) {
+	fn(&Group{app: a, prefix: cleanPrefix(prefix)})
+}
+
+// Group registers the routes added in fn under prefix, inside g. The
+// subgroup inherits g's middleware and guards.
+func (g *Group) Group(prefix string, fn func(g *Group)) {
+	fn(&Group{app: g.app, parent: g, prefix: g.path(prefix)})
+}
+
+// Route registers a page at path within the group.
+func (g *Group) Route(path string, page PageFunc, opts ...RouteOption) {
+	g.app.router.Add(g.path(path), page, append(opts, func(r *Route) { r.group = g })...)
+}
+
+// Layout registers a layout for a prefix within the group.
+func (g *Group) Layout(prefix string, layout LayoutFunc) {
+	g.app.router.AddLayout(g.path(prefix), layout)
+}
+
+// Use adds middleware that wraps HTTP requests for the group's pages,
+// including the WebSocket upgrade of a session on one of them.
+func (g *Group) Use(mw Middleware) { g.middleware = append(g.middleware, mw) }
+
+// Guard adds guards that every page and endpoint of the group must pass,
+// in order. They apply to routes added before and after the call.
+func (g *Group) Guard(guards ...Guard) { g.guards = append(g.guards, guards...) }
+
+func (g *Group) path(p string) string {
+	p = cleanPrefix(p)
+	if p == "/" {
+		return g.prefix
+	}
+	if g.prefix == "/" {
+		return p
+	}
+	return g.prefix + p
+}
+
+// groupChain returns the groups of route, outermost first. A nil route
+// (a not-found page) has none.
+func groupChain(route *Route) []*Group {
+	if route == nil {
+		return nil
+	}
+	var chain []*Group
+	for g := route.group; g != nil; g = g.parent {
+		chain = append([]*Group{g}, chain...)
+	}
+	return chain
+}
+
+// routeGuards returns the guards of route's groups, outermost first.
+func routeGuards(route *Route) []Guard {
+	var guards []Guard
+	for _, g := range groupChain(route) {
+		guards = append(guards, g.guards...)
+	}
+	return guards
+}
+
+// routeHandler wraps h in the middleware of route's groups, outermost
+// group first.
+func routeHandler(route *Route, h http.Handler) http.Handler {
+	chain := groupChain(route)
+	for i := len(chain) - 1; i >= 0; i-- {
+		mw := chain[i].middleware
+		for j := len(mw) - 1; j >= 0; j-- {
+			h = mw[j](h)
+		}
+	}
+	return h
+}
+
+// checkGuards runs guards in order and returns the first refusal.
+func checkGuards(c *ctx.Context, guards []Guard) error {
+	for _, guard := range guards {
+		if err := guard(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
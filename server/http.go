@@ -1,8 +1,9 @@
 package server
 
 import (
-	_ "embed"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 
@@ -11,18 +12,18 @@ import (
 	"github.com/Shravanthh/forge/ui"
 )
 
-//go:embed wasm/forge.wasm
-var forgeWASM []byte
-
-//go:embed wasm/wasm_exec.js
-var wasmExecJS []byte
-
 // App is the main Forge application.
 type App struct {
 	sessions   *SessionManager
 	router     *Router
-	uploads    map[string]UploadHandler
+	uploads    map[string]*uploadEndpoint
 	middleware []Middleware
+	client     Client
+	wasm       *asset // Custom WASM bundle from UseWASM, or nil
+	notFound   PageFunc
+	errorPage  ErrorPageFunc
+	static     []*staticDir
+	base       string // Base path from BasePath, "" at the root
 }
 
 // LayoutFunc wraps a page with layout.
@@ -30,98 +31,236 @@ type LayoutFunc func(*ctx.Context, ui.UI) ui.UI
 
 // New creates a new Forge application.
 func New() *App {
-	return &App{
+	a := &App{
 		sessions: NewSessionManager(nil),
 		router:   NewRouter(),
-		uploads:  make(map[string]UploadHandler),
+		uploads:  make(map[string]*uploadEndpoint),
 	}
+	a.sessions.newContext = a.newContext
+	runtimeAssets() // Hash and compress the client runtime before the first request
+	return a
+}
+
+// newContext creates a Context for a page of the app.
+func (a *App) newContext() *ctx.Context {
+	c := ctx.New()
+	c.SetURLFunc(a.URL)
+	c.SetAssetFunc(a.Asset)
+	return c
 }
 
 // Route registers a page handler.
-func (a *App) Route(path string, page PageFunc) { a.router.Add(path, page) }
+func (a *App) Route(path string, page PageFunc, opts ...RouteOption) {
+	a.router.Add(path, page, opts...)
+}
 
-// Layout registers a layout for a path prefix.
+// Routes lists the registered routes with their names, methods and
+// layouts, in order of precedence.
+//
+//	for _, r := range app.Routes() {
+//	    fmt.Println(r.Pattern, r.Name, r.Methods, r.Layouts)
+//	}
+func (a *App) Routes() []RouteInfo { return a.router.Routes() }
+
+// Layout registers a layout for a path prefix. Layouts wrap every page
+// under the prefix, innermost (deepest prefix) first, and see the page's
+// route parameters in c.Params.
 func (a *App) Layout(prefix string, layout LayoutFunc) { a.router.AddLayout(prefix, layout) }
 
+// resolved is a request path matched to its route.
+type resolved struct {
+	route  *Route
+	page   PageFunc // route.Page wrapped in its layouts
+	params map[string]string
+	guards []Guard
+}
+
+// resolve matches path to a route and wraps its page in the layouts
+// covering path. It returns nil if no route matches or the route has
+// only endpoints.
+func (a *App) resolve(path string) *resolved {
+	route, params := a.router.Lookup(path)
+	if route == nil || route.Page == nil {
+		return nil
+	}
+	return a.pageFor(route, params, path)
+}
+
+// pageFor prepares route's page for path.
+func (a *App) pageFor(route *Route, params map[string]string, path string) *resolved {
+	if params == nil {
+		params = make(map[string]string)
+	}
+	return &resolved{
+		route:  route,
+		page:   withLayouts(route.Page, a.router.LayoutsFor(route, path)),
+		params: params,
+		guards: routeGuards(route),
+	}
+}
+
+// withLayouts wraps page in layouts, innermost first.
+func withLayouts(page PageFunc, layouts []LayoutFunc) PageFunc {
+	if len(layouts) == 0 {
+		return page
+	}
+	return func(c *ctx.Context) ui.UI {
+		content := page(c)
+		for _, layout := range layouts {
+			content = layout(c, content)
+		}
+		return content
+	}
+}
+
 // ServeHTTP implements http.Handler.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = a.stripBase(r)
 	path := r.URL.Path
 
 	// File uploads
-	if handler, ok := a.uploads[path]; ok {
-		handleUpload(w, r, handler)
+	if ep, ok := a.uploads[path]; ok {
+		handleUpload(w, r, ep)
 		return
 	}
 
-	switch path {
-	case "/forge.wasm":
-		w.Header().Set("Content-Type", "application/wasm")
-		w.Write(forgeWASM)
+	if strings.HasPrefix(path, downloadPrefix) {
+		a.sessions.downloads.serve(w, r)
+		return
+	}
+	if asset := runtimeAssets().byURL[path]; asset != nil {
+		asset.serve(w, r)
 		return
+	}
+	if a.wasm != nil && path == a.wasm.url {
+		a.wasm.serve(w, r)
+		return
+	}
+	if a.serveStatic(w, r) {
+		return
+	}
+
+	switch path {
 	case "/ws":
-		pagePath := r.URL.Query().Get("path")
+		pagePath := a.pagePath(r.URL.Query().Get("path"))
 		if pagePath == "" {
 			pagePath = "/"
 		}
-		page, params := a.router.Match(pagePath)
-		if page == nil {
-			page, params = a.router.Match("/")
+		m := a.resolve(pagePath)
+		if m == nil {
+			// Unknown paths get the live not-found page, never another route.
+			page := a.notFoundPage(pagePath)
+			if page == nil {
+				http.NotFound(w, r)
+				return
+			}
+			m = &resolved{page: page, params: map[string]string{}}
 		}
-		a.sessions.HandleWebSocket(page, params)(w, r)
+		routeHandler(m.route, a.sessions.handleWebSocket(m.page, m.params, m.guards)).ServeHTTP(w, r)
 		return
 	}
 
-	page, params := a.router.Match(path)
-	if page == nil {
-		http.NotFound(w, r)
+	route, params, allowed := a.router.LookupMethod(r.Method, path)
+	if route == nil {
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		c := a.newContext()
+		c.SetQuery(r.URL.Query())
+		c.SetRoles(requestRoles(r)...)
+		a.renderNotFound(w, r, c, true)
 		return
 	}
+	if h := route.handler(r.Method); h != nil {
+		for k, v := range params {
+			r.SetPathValue(k, v)
+		}
+		routeHandler(route, a.guardEndpoint(h, routeGuards(route), params)).ServeHTTP(w, r)
+		return
+	}
+	m := a.pageFor(route, params, path)
+	routeHandler(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.renderPage(w, r, m)
+	})).ServeHTTP(w, r)
+}
 
-	c := ctx.New()
-	c.Params = params
-	ui.ResetEventCounter()
-	content := page(c)
-
-	for _, layout := range a.router.GetLayouts(path) {
-		content = layout(c, content)
+// renderPage renders a matched page for an HTTP request, unless one of
+// its guards refuses it.
+func (a *App) renderPage(w http.ResponseWriter, r *http.Request, m *resolved) {
+	c := a.newContext()
+	c.Params = m.params
+	c.SetQuery(r.URL.Query())
+	c.SetRoles(requestRoles(r)...)
+	if err := checkGuards(c, m.guards); err != nil {
+		var redirect *RedirectError
+		switch {
+		case errors.As(err, &redirect):
+			http.Redirect(w, r, redirect.URL, http.StatusSeeOther)
+		case errors.Is(err, ErrNotFound):
+			a.renderNotFound(w, r, c, false)
+		default:
+			a.renderError(w, r, c, http.StatusForbidden, err)
+		}
+		return
+	}
+	content, err := renderSafe(m.page, c)
+	if err != nil {
+		a.renderError(w, r, c, http.StatusInternalServerError, err)
+		return
 	}
+	a.writePage(w, http.StatusOK, content)
+}
 
+// writePage sends a rendered page with status.
+func (a *App) writePage(w http.ResponseWriter, status int, content ui.UI) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprint(w, wrapHTML(render.HTML(content)))
+	w.WriteHeader(status)
+	writeHTML(w, content, a.client, a.wasm, a.base)
 }
 
-func wrapHTML(body string) string {
-	var headScripts, bodyScripts strings.Builder
-	for _, s := range ui.GetHeadScripts() {
-		headScripts.WriteString(s)
-		headScripts.WriteByte('\n')
+// writeHTML streams a full page with the client runtime, served under
+// base, to w.
+func writeHTML(w io.Writer, content ui.UI, client Client, wasm *asset, base string) error {
+	io.WriteString(w, docStart)
+	io.WriteString(w, ui.GetCSS())
+	io.WriteString(w, "</style>\n")
+	writeScripts(w, ui.GetHeadScripts())
+	io.WriteString(w, "\n</head>\n<body>\n")
+	if err := render.Write(w, content); err != nil {
+		return err
 	}
-	for _, s := range ui.GetBodyScripts() {
-		bodyScripts.WriteString(s)
-		bodyScripts.WriteByte('\n')
+	io.WriteString(w, "\n")
+	writeScripts(w, ui.GetBodyScripts())
+	io.WriteString(w, "\n")
+	if err := writeClient(w, client, wasm, base); err != nil {
+		return err
 	}
+	_, err := io.WriteString(w, "\n</body>\n</html>")
+	return err
+}
 
-	return `<!DOCTYPE html>
+const docStart = `<!DOCTYPE html>
 <html>
 <head>
 <meta charset="UTF-8">
 <meta name="viewport" content="width=device-width,initial-scale=1">
 <title>Forge App</title>
-<style>` + ui.GetCSS() + `</style>
-` + headScripts.String() + `
-</head>
-<body>
-` + body + `
-` + bodyScripts.String() + `
-<script>` + string(wasmExecJS) + `
-const go=new Go();WebAssembly.instantiateStreaming(fetch("/forge.wasm"),go.importObject).then(r=>go.run(r.instance));
-</script>
-</body>
-</html>`
+<style>`
+
+func writeScripts(w io.Writer, scripts []string) {
+	for _, s := range scripts {
+		io.WriteString(w, s)
+		io.WriteString(w, "\n")
+	}
 }
 
 // Run starts the server.
 func (a *App) Run(addr string) error {
+	if err := a.Check(); err != nil {
+		return err
+	}
 	fmt.Printf("Forge running at http://localhost%s\n", addr)
 	var handler http.Handler = a
 	for i := len(a.middleware) - 1; i >= 0; i-- {
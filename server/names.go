@@ -0,0 +1,164 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Name names a route so links can be built with App.URL, c.URL and
+// Element.Route instead of by hand. Names are unique; Name panics if one
+// is already taken by another pattern.
+//
+//	app.Route("/user/:id<int>", UserPage, forge.Name("user"))
+func Name(name string) RouteOption {
+	return func(r *Route) { r.Name = name }
+}
+
+// URL builds the path of the route named name. params are key-value
+// pairs for its parameters: every parameter must be given unless it is
+// optional or a wildcard, and values must satisfy their constraints.
+// Values are path-escaped; a wildcard value keeps its slashes.
+//
+// A name that is not registered yet is remembered, and CheckNames
+// reports it.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	route := r.named(name)
+	if route == nil {
+		r.mu.Lock()
+		if r.unresolved == nil {
+			r.unresolved = make(map[string]bool)
+		}
+		r.unresolved[name] = true
+		r.mu.Unlock()
+		return "", fmt.Errorf("forge: unknown route %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("forge: route %q: odd number of parameters", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	var b strings.Builder
+	omitted := ""
+	for _, seg := range route.segments {
+		if seg.kind == segStatic {
+			b.WriteString("/" + seg.value)
+			continue
+		}
+		v, ok := values[seg.value]
+		delete(values, seg.value)
+		switch {
+		case seg.kind == segWildcard:
+			for _, part := range splitPath(v) {
+				b.WriteString("/" + url.PathEscape(part))
+			}
+			continue
+		case !ok && seg.kind == segOptional:
+			omitted = seg.value
+			continue
+		case !ok:
+			return "", fmt.Errorf("forge: route %q: missing parameter %q", name, seg.value)
+		case omitted != "":
+			return "", fmt.Errorf("forge: route %q: parameter %q given without %q", name, seg.value, omitted)
+		case v == "" || seg.re != nil && !seg.re.MatchString(v):
+			return "", fmt.Errorf("forge: route %q: invalid value %q for parameter %q", name, v, seg.value)
+		}
+		b.WriteString("/" + url.PathEscape(v))
+	}
+	for k := range values {
+		return "", fmt.Errorf("forge: route %q has no parameter %q", name, k)
+	}
+	if b.Len() == 0 {
+		return "/", nil
+	}
+	return b.String(), nil
+}
+
+// named returns the route called name, or nil.
+func (r *Router) named(name string) *Route {
+	for _, route := range r.routes {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// checkName panics if name is taken by a route other than route.
+func (r *Router) checkName(route *Route) {
+	if route.Name == "" {
+		return
+	}
+	for _, other := range r.routes {
+		if other != route && other.Name == route.Name {
+			panic(fmt.Sprintf("forge: route name %q used by %q and %q", route.Name, other.Pattern, route.Pattern))
+		}
+	}
+}
+
+// CheckNames reports the route names URL was asked for but did not
+// know: names never registered, and names registered only after they
+// were used.
+func (r *Router) CheckNames() error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.unresolved))
+	for name := range r.unresolved {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if r.named(name) != nil {
+			errs = append(errs, fmt.Errorf("forge: route %q used before it was registered", name))
+		} else {
+			errs = append(errs, fmt.Errorf("forge: unknown route %q", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Check reports mistakes in the app's setup: names passed to App.URL that
+// were not registered at the time. Run refuses to start on them; an app
+// mounted on another mux with ServeHTTP never calls Run, so call Check
+// after registering its routes.
+//
+// Names used inside pages (c.URL, Element.Route, ui.BreadcrumbsFor) are
+// only looked up when a page renders, so Check cannot see them.
+// GenerateStatic renders every page and reports those too; a live app
+// logs them and links to "#".
+func (a *App) Check() error {
+	return a.router.CheckNames()
+}
+
+// URL builds the path of the route named name from key-value params,
+// such as app.URL("user", "id", "42"), including the base path. Use it while setting up the app,
+// after the route is registered; Check and Run report a name that was
+// unknown. In pages, use c.URL.
+//
+// An unknown name or invalid parameters are logged and give "#".
+func (a *App) URL(name string, params ...string) string {
+	u, err := a.router.URL(name, params...)
+	if err != nil {
+		// Log each problem once rather than on every re-render.
+		a.router.mu.Lock()
+		logged := a.router.logged[err.Error()]
+		if a.router.logged == nil {
+			a.router.logged = make(map[string]bool)
+		}
+		a.router.logged[err.Error()] = true
+		a.router.mu.Unlock()
+		if !logged {
+			log.Print(err)
+		}
+		return "#"
+	}
+	return a.base + u
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppCheck(t *testing.T) {
+	app := New()
+	app.Route("/", page, Name("home"))
+	app.URL("home")
+	if err := app.Check(); err != nil {
+		t.Fatalf("Check = %v; want nil", err)
+	}
+
+	app.URL("user", "id", "1")
+	app.URL("missing")
+	app.Route("/user/:id", page, Name("user"))
+	err := app.Check()
+	if err == nil {
+		t.Fatal("Check = nil; want errors for missing and user")
+	}
+	for _, want := range []string{`unknown route "missing"`, `route "user" used before it was registered`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Check = %v; want it to mention %s", err, want)
+		}
+	}
+}
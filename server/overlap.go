@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"unicode"
+)
+
+// checkOverlap panics if a constrained segment of segs would share a
+// tree node with another route's constraint that matches some of the
+// same segments, such as :id<int> and :n<[0-9]+>. Which of the two a
+// path took would depend on registration order. It runs before the
+// route is inserted, so a panic leaves the router unchanged.
+func (r *Router) checkOverlap(pattern string, segs []segment) {
+	n := r.root
+	for _, seg := range segs {
+		if seg.kind == segStatic {
+			if n = n.static[seg.value]; n == nil {
+				return
+			}
+			continue
+		}
+		shape := segmentShape(seg)
+		var next *node
+		for _, e := range n.dynamic {
+			switch {
+			case e.shape == shape:
+				next = e.child
+			case e.seg.kind == seg.kind && e.seg.re != nil && seg.re != nil && overlaps(e.seg.re, seg.re):
+				panic(fmt.Sprintf("forge: route %q conflicts with %q: their constraints match the same segments", pattern, e.pattern))
+			}
+		}
+		if next == nil {
+			return
+		}
+		n = next
+	}
+}
+
+// overlaps reports whether a non-empty path segment matches both a and
+// b. It walks the two compiled programs in step, one rune class at a
+// time. Assertions such as \b are assumed to hold, so overlaps may
+// report patterns that only differ in them.
+func overlaps(a, b *regexp.Regexp) bool {
+	pa, pb := program(a), program(b)
+	type state struct {
+		a, b     uint32
+		nonEmpty bool
+	}
+	seen := make(map[state]bool)
+	var queue []state
+	push := func(as, bs []uint32, nonEmpty bool) {
+		for _, x := range as {
+			for _, y := range bs {
+				s := state{x, y, nonEmpty}
+				if !seen[s] {
+					seen[s] = true
+					queue = append(queue, s)
+				}
+			}
+		}
+	}
+	push(closure(pa, uint32(pa.Start)), closure(pb, uint32(pb.Start)), false)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		ia, ib := &pa.Inst[s.a], &pb.Inst[s.b]
+		if ia.Op == syntax.InstMatch || ib.Op == syntax.InstMatch {
+			if ia.Op == ib.Op && s.nonEmpty {
+				return true
+			}
+			continue
+		}
+		if classesMeet(runeRanges(ia), runeRanges(ib)) {
+			push(closure(pa, ia.Out), closure(pb, ib.Out), true)
+		}
+	}
+	return false
+}
+
+func program(re *regexp.Regexp) *syntax.Prog {
+	// re compiled, so it parses and compiles again.
+	parsed, _ := syntax.Parse(re.String(), syntax.Perl)
+	prog, _ := syntax.Compile(parsed.Simplify())
+	return prog
+}
+
+// closure returns the instructions that consume a rune or match,
+// reachable from pc without consuming input.
+func closure(prog *syntax.Prog, pc uint32) []uint32 {
+	var out []uint32
+	seen := make(map[uint32]bool)
+	var visit func(uint32)
+	visit = func(pc uint32) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+		inst := &prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			visit(inst.Out)
+			visit(inst.Arg)
+		case syntax.InstCapture, syntax.InstNop, syntax.InstEmptyWidth:
+			visit(inst.Out)
+		case syntax.InstFail:
+		default:
+			out = append(out, pc)
+		}
+	}
+	visit(pc)
+	return out
+}
+
+// runeRanges returns the runes a consuming instruction accepts as
+// inclusive [lo, hi] pairs.
+func runeRanges(inst *syntax.Inst) []rune {
+	switch inst.Op {
+	case syntax.InstRuneAny:
+		return []rune{0, unicode.MaxRune}
+	case syntax.InstRuneAnyNotNL:
+		return []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}
+	case syntax.InstRune1:
+		return []rune{inst.Rune[0], inst.Rune[0]}
+	}
+	if len(inst.Rune) == 1 {
+		// A single rune, possibly with its case variants.
+		r := inst.Rune[0]
+		ranges := []rune{r, r}
+		if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				ranges = append(ranges, f, f)
+			}
+		}
+		return ranges
+	}
+	return inst.Rune
+}
+
+// classesMeet reports whether two rune classes share a rune other than
+// '/', which never occurs in a path segment.
+func classesMeet(a, b []rune) bool {
+	for i := 0; i+1 < len(a); i += 2 {
+		for j := 0; j+1 < len(b); j += 2 {
+			lo, hi := max(a[i], b[j]), min(a[i+1], b[j+1])
+			if lo <= hi && !(lo == '/' && hi == '/') {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/Shravanthh/forge/diff"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket subprotocols offered by clients. A client that offers none
+// gets the JSON protocol.
+const (
+	ProtocolBinary = "forge.v1.bin"
+	ProtocolJSON   = "forge.v1.json"
+)
+
+// binaryVersion is the first byte of every binary patch frame.
+const binaryVersion = 1
+
+// batchWindow is how long patches are collected before being sent, so
+// rapid events (keystrokes, scrolling) share one frame.
+const batchWindow = 10 * time.Millisecond
+
+// maxInterned bounds the per-connection string table.
+const maxInterned = 1 << 16
+
+// Binary patch type codes.
+var patchCodes = map[diff.PatchType]byte{
+	diff.Replace:    1,
+	diff.UpdateAttr: 2,
+	diff.UpdateText: 3,
+	diff.Insert:     4,
+	diff.Remove:     5,
+	diff.Move:       6,
+}
+
+// binaryEncoder encodes patch batches in the compact binary format:
+//
+//	frame   = version:byte count:uvarint patch*
+//	patch   = code:byte id:str fields
+//	replace = html:lit
+//	attrs   = n:uvarint (key:str value:lit)* m:uvarint key:str*
+//	text    = text:lit
+//	insert  = parent:str before:str html:lit
+//	remove  = (nothing)
+//	move    = parent:str before:str
+//	lit     = len:uvarint bytes
+//	str     = 0 lit   (new string, appended to the table)
+//	        | 1 lit   (table full, not stored)
+//	        | 2+i     (reference to table entry i)
+//
+// IDs and attribute names repeat across patches and frames, so each is
+// sent once per connection and referenced by index afterwards. The table
+// lives as long as the connection; the client keeps an identical one.
+// Strings new in a frame join the table only once the frame is written
+// (commit), and the client adds them only once the whole frame decodes,
+// so a frame that is lost or rejected leaves both tables as they were.
+type binaryEncoder struct {
+	table map[string]uint64
+	added []string // New strings of the last frame, not yet committed
+	buf   []byte
+}
+
+func newBinaryEncoder() *binaryEncoder {
+	return &binaryEncoder{table: make(map[string]uint64)}
+}
+
+// encode returns the frame for patches. The result is reused by the next
+// call. Call commit once the frame has been sent.
+func (e *binaryEncoder) encode(patches []diff.Patch) []byte {
+	e.discard()
+	e.buf = append(e.buf[:0], binaryVersion)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(patches)))
+	for _, p := range patches {
+		e.buf = append(e.buf, patchCodes[p.Type])
+		e.str(p.ID)
+		switch p.Type {
+		case diff.Replace:
+			e.lit(p.HTML)
+		case diff.UpdateAttr:
+			e.buf = binary.AppendUvarint(e.buf, uint64(len(p.Attrs)))
+			for k, v := range p.Attrs {
+				e.str(k)
+				e.lit(v)
+			}
+			e.buf = binary.AppendUvarint(e.buf, uint64(len(p.Unset)))
+			for _, k := range p.Unset {
+				e.str(k)
+			}
+		case diff.UpdateText:
+			e.lit(p.Text)
+		case diff.Insert:
+			e.str(p.Parent)
+			e.str(p.Before)
+			e.lit(p.HTML)
+		case diff.Move:
+			e.str(p.Parent)
+			e.str(p.Before)
+		}
+	}
+	return e.buf
+}
+
+// commit keeps the strings the last frame added to the table.
+func (e *binaryEncoder) commit() { e.added = e.added[:0] }
+
+// discard removes the strings the last frame added to the table.
+func (e *binaryEncoder) discard() {
+	for _, s := range e.added {
+		delete(e.table, s)
+	}
+	e.added = e.added[:0]
+}
+
+func (e *binaryEncoder) str(s string) {
+	if i, ok := e.table[s]; ok {
+		e.buf = binary.AppendUvarint(e.buf, i+2)
+		return
+	}
+	if len(e.table) < maxInterned {
+		e.table[s] = uint64(len(e.table))
+		e.added = append(e.added, s)
+		e.buf = append(e.buf, 0)
+	} else {
+		e.buf = append(e.buf, 1)
+	}
+	e.lit(s)
+}
+
+func (e *binaryEncoder) lit(s string) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// outbox serializes writes to a session connection and batches patches.
+// gorilla/websocket allows only one concurrent writer.
+type outbox struct {
+	conn      *websocket.Conn
+	enc       *binaryEncoder // nil for the JSON protocol
+	mu        sync.Mutex
+	pending   []diff.Patch
+	scheduled bool
+	writeMu   sync.Mutex
+	closed    bool // Guarded by writeMu
+}
+
+func newOutbox(conn *websocket.Conn) *outbox {
+	o := &outbox{conn: conn}
+	if conn.Subprotocol() == ProtocolBinary {
+		o.enc = newBinaryEncoder()
+	}
+	return o
+}
+
+// queue adds patches to the next batch, flushed after batchWindow.
+func (o *outbox) queue(patches []diff.Patch) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending = append(o.pending, patches...)
+	if !o.scheduled {
+		o.scheduled = true
+		time.AfterFunc(batchWindow, o.flush)
+	}
+}
+
+// flush sends all pending patches as one frame.
+func (o *outbox) flush() {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+	o.flushLocked()
+}
+
+func (o *outbox) flushLocked() {
+	o.mu.Lock()
+	patches := o.pending
+	o.pending = nil
+	o.scheduled = false
+	o.mu.Unlock()
+
+	if len(patches) == 0 || o.closed {
+		return
+	}
+	if o.enc != nil {
+		if o.conn.WriteMessage(websocket.BinaryMessage, o.enc.encode(patches)) == nil {
+			o.enc.commit()
+		} else {
+			o.enc.discard()
+		}
+		return
+	}
+	o.conn.WriteJSON(Response{Type: "patch", Patches: patches})
+}
+
+// close sends the patches still pending and stops further writes. The
+// caller closes the connection afterwards.
+func (o *outbox) close() {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+	o.flushLocked()
+	o.closed = true
+}
+
+// send writes a JSON control message after any pending patches, so the
+// client sees them in the order they were produced.
+func (o *outbox) send(v any) {
+	o.writeMu.Lock()
+	defer o.writeMu.Unlock()
+	o.flushLocked()
+	if !o.closed {
+		o.conn.WriteJSON(v)
+	}
+}
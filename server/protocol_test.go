@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Shravanthh/forge/diff"
+)
+
+// frameReader decodes binary patch frames like the clients do, keeping
+// the strings a frame adds only if the whole frame decodes.
+type frameReader struct {
+	table []string
+}
+
+var errFrame = errors.New("malformed frame")
+
+func (f *frameReader) decode(b []byte) (patches []diff.Patch, err error) {
+	types := map[byte]diff.PatchType{}
+	for t, code := range patchCodes {
+		types[code] = t
+	}
+	var added []string
+	pos := 0
+	next := func() byte {
+		if pos >= len(b) {
+			panic(errFrame)
+		}
+		pos++
+		return b[pos-1]
+	}
+	uvarint := func() uint64 {
+		x, n := binary.Uvarint(b[pos:])
+		if n <= 0 {
+			panic(errFrame)
+		}
+		pos += n
+		return x
+	}
+	lit := func() string {
+		n := int(uvarint())
+		if pos+n > len(b) {
+			panic(errFrame)
+		}
+		pos += n
+		return string(b[pos-n : pos])
+	}
+	str := func() string {
+		switch tag := uvarint(); tag {
+		case 0:
+			s := lit()
+			added = append(added, s)
+			return s
+		case 1:
+			return lit()
+		default:
+			i := int(tag - 2)
+			if i < len(f.table) {
+				return f.table[i]
+			}
+			if i -= len(f.table); i < len(added) {
+				return added[i]
+			}
+			panic(fmt.Errorf("%w: no string %d", errFrame, tag-2))
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			patches, err = nil, r.(error)
+		}
+	}()
+
+	if v := next(); v != binaryVersion {
+		return nil, fmt.Errorf("version %d", v)
+	}
+	for n := uvarint(); n > 0; n-- {
+		t, ok := types[next()]
+		if !ok {
+			return nil, errFrame
+		}
+		p := diff.Patch{Type: t, ID: str()}
+		switch t {
+		case diff.Replace:
+			p.HTML = lit()
+		case diff.UpdateAttr:
+			p.Attrs = map[string]string{}
+			for n := uvarint(); n > 0; n-- {
+				k := str()
+				p.Attrs[k] = lit()
+			}
+			for n := uvarint(); n > 0; n-- {
+				p.Unset = append(p.Unset, str())
+			}
+		case diff.UpdateText:
+			p.Text = lit()
+		case diff.Insert:
+			p.Parent, p.Before, p.HTML = str(), str(), lit()
+		case diff.Move:
+			p.Parent, p.Before = str(), str()
+		}
+		patches = append(patches, p)
+	}
+	if pos != len(b) {
+		return nil, fmt.Errorf("%w: %d trailing bytes", errFrame, len(b)-pos)
+	}
+	f.table = append(f.table, added...)
+	return patches, nil
+}
+
+func TestBinaryEncoderRoundTrip(t *testing.T) {
+	enc := newBinaryEncoder()
+	var client frameReader
+	send := func(t *testing.T, patches []diff.Patch) []byte {
+		t.Helper()
+		frame := enc.encode(patches)
+		enc.commit()
+		got, err := client.decode(frame)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, patches) {
+			t.Fatalf("decoded\n%+v\nwant\n%+v", got, patches)
+		}
+		return frame
+	}
+
+	first := []diff.Patch{
+		{Type: diff.Replace, ID: "0.1", HTML: "<p>x</p>"},
+		{Type: diff.UpdateAttr, ID: "btn", Attrs: map[string]string{"class": "on", "title": "btn"}, Unset: []string{"disabled"}},
+		{Type: diff.UpdateText, ID: "0.2", Text: "héllo"},
+		{Type: diff.Insert, ID: "c", Parent: "list", Before: "d", HTML: "<li>c</li>"},
+		{Type: diff.Move, ID: "d", Parent: "list"},
+		{Type: diff.Remove, ID: "0.1"},
+	}
+	var firstLen int
+	t.Run("first frame", func(t *testing.T) { firstLen = len(send(t, first)) })
+
+	t.Run("strings are referenced in later frames", func(t *testing.T) {
+		if again := send(t, first); len(again) >= firstLen {
+			t.Errorf("repeated frame is %d bytes; want fewer than %d", len(again), firstLen)
+		}
+		tableLen := len(enc.table)
+		send(t, []diff.Patch{{Type: diff.UpdateAttr, ID: "btn", Attrs: map[string]string{"class": "off"}}})
+		if len(enc.table) != tableLen || len(client.table) != tableLen {
+			t.Errorf("table sizes = %d, %d; want %d", len(enc.table), len(client.table), tableLen)
+		}
+	})
+
+	t.Run("discard after a failed write", func(t *testing.T) {
+		tableLen := len(enc.table)
+		lost := []diff.Patch{{Type: diff.Insert, ID: "new1", Parent: "new2", HTML: "<i></i>"}}
+		enc.encode(lost) // The write fails and the client never sees it.
+		enc.discard()
+		if len(enc.table) != tableLen {
+			t.Fatalf("table has %d strings after discard; want %d", len(enc.table), tableLen)
+		}
+		send(t, append(lost, diff.Patch{Type: diff.Remove, ID: "new1"}))
+	})
+
+	t.Run("encode discards an uncommitted frame", func(t *testing.T) {
+		enc.encode([]diff.Patch{{Type: diff.Remove, ID: "skipped"}})
+		send(t, []diff.Patch{{Type: diff.Remove, ID: "skipped"}, {Type: diff.Remove, ID: "kept"}})
+	})
+}
+
+func TestBinaryEncoderTableFull(t *testing.T) {
+	enc := newBinaryEncoder()
+	var client frameReader
+	send := func(patches []diff.Patch) {
+		t.Helper()
+		frame := enc.encode(patches)
+		enc.commit()
+		got, err := client.decode(frame)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, patches) {
+			t.Fatalf("decoded %d patches, which differ from the %d sent", len(got), len(patches))
+		}
+	}
+
+	fill := make([]diff.Patch, maxInterned-1)
+	for i := range fill {
+		fill[i] = diff.Patch{Type: diff.Remove, ID: fmt.Sprintf("n%d", i)}
+	}
+	send(fill)
+
+	// The last free entry goes to "last"; "over" arrives after the table
+	// is full and is sent as a literal every time.
+	overflow := []diff.Patch{
+		{Type: diff.Remove, ID: "last"},
+		{Type: diff.Remove, ID: "over"},
+		{Type: diff.Remove, ID: "over"},
+		{Type: diff.Remove, ID: "n0"},
+	}
+	send(overflow)
+	send(overflow)
+	if len(enc.table) != maxInterned || len(client.table) != maxInterned {
+		t.Errorf("table sizes = %d, %d; want %d", len(enc.table), len(client.table), maxInterned)
+	}
+	if _, ok := enc.table["over"]; ok {
+		t.Error(`"over" was interned past maxInterned`)
+	}
+}
@@ -1,94 +1,611 @@
 package server
 
-import "strings"
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
 
 // Route holds route info.
 type Route struct {
-	Pattern  string
-	Page     PageFunc
-	segments []string
-	params   []string
+	Pattern   string
+	Name      string // Set with the Name option
+	Page      PageFunc
+	segments  []segment
+	params    []string                // Names of the parameter segments, in order
+	noLayout  []string                // Layout prefixes skipped for this route
+	layoutOff bool                    // Skip every layout
+	group     *Group                  // Group the route was added in, or nil
+	handlers  map[string]http.Handler // Endpoints by method
 }
 
-// Router handles URL routing.
+// handler returns the endpoint for method. HEAD falls back to GET.
+func (r *Route) handler(method string) http.Handler {
+	if h := r.handlers[method]; h != nil || method != http.MethodHead {
+		return h
+	}
+	return r.handlers[http.MethodGet]
+}
+
+// handles reports whether the route answers method, with its page (GET
+// and HEAD) or an endpoint.
+func (r *Route) handles(method string) bool {
+	if r.Page != nil && (method == http.MethodGet || method == http.MethodHead) {
+		return true
+	}
+	return r.handler(method) != nil
+}
+
+// methods returns the methods the route answers, for an Allow header.
+func (r *Route) methods() []string {
+	var methods []string
+	for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if r.handles(m) {
+			methods = append(methods, m)
+		}
+	}
+	for m := range r.handlers {
+		if !slices.Contains(methods, m) {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// RouteOption configures a route registered with App.Route.
+type RouteOption func(*Route)
+
+// NoLayout excludes layouts from a route: every layout when called
+// without arguments, otherwise only those registered at prefixes.
+//
+//	app.Route("/admin/login", LoginPage, forge.NoLayout("/admin"))
+func NoLayout(prefixes ...string) RouteOption {
+	return func(r *Route) {
+		if len(prefixes) == 0 {
+			r.layoutOff = true
+		}
+		for _, p := range prefixes {
+			r.noLayout = append(r.noLayout, cleanPrefix(p))
+		}
+	}
+}
+
+// segmentKind orders segments by precedence: when two routes match a
+// path, the one with the lower kind at the first differing segment wins.
+type segmentKind int
+
+const (
+	segEnd      segmentKind = iota // Pattern ended; a route ending first wins over optional tails
+	segStatic                      // about
+	segTyped                       // :id<int>
+	segParam                       // :id
+	segOptional                    // :id?
+	segWildcard                    // *path
+)
+
+type segment struct {
+	kind  segmentKind
+	value string         // Static text or parameter name
+	re    *regexp.Regexp // Constraint of a typed parameter, or nil
+}
+
+// constraints are the named parameter types. Any other constraint is
+// a regular expression matched against the whole segment.
+var constraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[A-Za-z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// layout is a layout registered at a path prefix.
+type layout struct {
+	prefix   string
+	segments []segment
+	fn       LayoutFunc
+}
+
+// Router handles URL routing. Routes are stored in a tree with one level
+// per path segment; routes without parameters are also indexed by path,
+// so matching them does not allocate.
 type Router struct {
-	routes  []*Route
-	layouts map[string]LayoutFunc
+	routes  []*Route          // By precedence
+	root    *node             // Tree of routes
+	static  map[string]*Route // Parameterless routes by path, e.g. "docs/intro"
+	shapes  map[string]*Route // Routes by shape, to detect conflicts
+	layouts []*layout         // Deepest prefix first
+
+	mu         sync.Mutex
+	unresolved map[string]bool // Names URL was asked for before they existed
+	logged     map[string]bool // URL errors already logged
+}
+
+// node is a position in the route tree: the segments leading to it are
+// a prefix shared by the routes below it.
+type node struct {
+	route   *Route           // Route whose pattern ends here, or nil
+	static  map[string]*node // Static children by segment text
+	dynamic []*edge          // Other children by kind, then registration order
+}
+
+// edge leads to the child for a parameter or wildcard segment.
+type edge struct {
+	seg     segment
+	shape   string
+	pattern string // Route that added the edge, for conflict messages
+	child   *node
 }
 
 // NewRouter creates a router.
 func NewRouter() *Router {
-	return &Router{layouts: make(map[string]LayoutFunc)}
+	return &Router{
+		root:   &node{},
+		static: make(map[string]*Route),
+		shapes: make(map[string]*Route),
+	}
+}
+
+// Add registers a route. Patterns are made of segments:
+//
+//	/docs             static
+//	/user/:id         parameter
+//	/user/:id<int>    parameter with a constraint (int, uint, alpha, alnum,
+//	                  uuid, or a regular expression such as :slug<[a-z-]+>)
+//	/archive/:year?   optional parameter; only optional segments may follow
+//	/files/*path      wildcard matching the rest of the path, even if empty
+//
+// Routes are tried by precedence rather than registration order: at the
+// first segment where two routes differ, static beats constrained beats
+// plain parameter beats optional beats wildcard. Add panics on a
+// malformed pattern, on a page registered twice, and on a pattern that
+// conflicts with another, which is when precedence cannot choose:
+//
+//   - both match exactly the same paths, such as /a/:x and /a/:y
+//   - after the same leading segments, both have a constrained
+//     parameter (or both a constrained optional one) whose constraints
+//     differ but match some of the same segments, such as /a/:id<int>
+//     and /a/:n<[0-9]+>, or /a/:id<int>/x and /a/:n<uint>/y
+//
+// Constraints matching disjoint segments, such as /a/:id<int> and
+// /a/:slug<alpha>, do not conflict.
+func (r *Router) Add(pattern string, page PageFunc, opts ...RouteOption) {
+	route := r.route(pattern, opts)
+	if route.Page != nil {
+		panic(fmt.Sprintf("forge: route %q: page registered twice", pattern))
+	}
+	route.Page = page
+}
+
+// AddHandler registers an endpoint for method at pattern. A pattern may
+// have a page and endpoints for several methods. AddHandler panics like
+// Add, and if method already has an endpoint.
+func (r *Router) AddHandler(method, pattern string, h http.Handler, opts ...RouteOption) {
+	route := r.route(pattern, opts)
+	if route.handlers[method] != nil {
+		panic(fmt.Sprintf("forge: route %q: %s endpoint registered twice", pattern, method))
+	}
+	if route.handlers == nil {
+		route.handlers = make(map[string]http.Handler)
+	}
+	route.handlers[method] = h
+}
+
+// route returns the route for pattern, adding it if needed. Patterns
+// that differ only in spelling ("/a/" and "/a") share a route.
+func (r *Router) route(pattern string, opts []RouteOption) *Route {
+	segs := parsePattern(pattern)
+	shape, params := shapeOf(segs)
+	route := r.shapes[shape]
+	switch {
+	case route == nil:
+		r.checkOverlap(pattern, segs)
+		route = &Route{Pattern: pattern, segments: segs, params: params}
+		r.insert(route, shape)
+	case !slices.Equal(route.params, params):
+		panic(fmt.Sprintf("forge: route %q conflicts with %q", pattern, route.Pattern))
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+	r.checkName(route)
+	return route
+}
+
+// shapeOf describes which paths segs match, ignoring parameter names,
+// and returns the parameter names.
+func shapeOf(segs []segment) (string, []string) {
+	var b strings.Builder
+	var params []string
+	for _, seg := range segs {
+		b.WriteString("/" + segmentShape(seg))
+		if seg.kind != segStatic {
+			params = append(params, seg.value)
+		}
+	}
+	return b.String(), params
+}
+
+func segmentShape(seg segment) string {
+	var s string
+	switch seg.kind {
+	case segStatic:
+		return seg.value
+	case segWildcard:
+		return "*"
+	case segOptional:
+		s = ":?"
+	default:
+		s = ":"
+	}
+	if seg.re != nil {
+		s += "<" + seg.re.String() + ">"
+	}
+	return s
 }
 
-// Add registers a route.
-func (r *Router) Add(pattern string, page PageFunc) {
-	route := &Route{Pattern: pattern, Page: page}
-	route.segments, route.params = parsePattern(pattern)
+// insert adds a new route to the tree and the indexes.
+func (r *Router) insert(route *Route, shape string) {
+	n := r.root
+	static := true
+	for _, seg := range route.segments {
+		if seg.kind == segStatic {
+			child := n.static[seg.value]
+			if child == nil {
+				child = &node{}
+				if n.static == nil {
+					n.static = make(map[string]*node)
+				}
+				n.static[seg.value] = child
+			}
+			n = child
+			continue
+		}
+		static = false
+		n = n.edge(seg, route.Pattern).child
+	}
+	n.route = route
+
+	if static {
+		parts := make([]string, len(route.segments))
+		for i, seg := range route.segments {
+			parts[i] = seg.value
+		}
+		r.static[strings.Join(parts, "/")] = route
+	}
+	r.shapes[shape] = route
 	r.routes = append(r.routes, route)
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return precedes(r.routes[i].segments, r.routes[j].segments)
+	})
 }
 
-// AddLayout registers a layout for a path prefix.
-func (r *Router) AddLayout(prefix string, layout LayoutFunc) {
-	r.layouts[prefix] = layout
+// edge returns the edge for a parameter or wildcard segment, adding it
+// for pattern if needed.
+func (n *node) edge(seg segment, pattern string) *edge {
+	shape := segmentShape(seg)
+	for _, e := range n.dynamic {
+		if e.shape == shape {
+			return e
+		}
+	}
+	e := &edge{seg: seg, shape: shape, pattern: pattern, child: &node{}}
+	n.dynamic = append(n.dynamic, e)
+	sort.SliceStable(n.dynamic, func(i, j int) bool {
+		return n.dynamic[i].seg.kind < n.dynamic[j].seg.kind
+	})
+	return e
+}
+
+// AddLayout registers a layout for a path prefix. The prefix matches
+// whole segments, so "/admin" covers /admin and /admin/users but not
+// /administrator; it may contain parameters ("/team/:id"). Registering
+// a prefix again replaces its layout.
+func (r *Router) AddLayout(prefix string, fn LayoutFunc) {
+	prefix = cleanPrefix(prefix)
+	l := &layout{prefix: prefix, segments: parsePattern(prefix), fn: fn}
+	for _, seg := range l.segments {
+		if seg.kind == segOptional || seg.kind == segWildcard {
+			panic("forge: layout " + prefix + ": only static and parameter segments are allowed")
+		}
+	}
+	for i, old := range r.layouts {
+		if old.prefix == prefix {
+			r.layouts[i] = l
+			return
+		}
+	}
+	r.layouts = append(r.layouts, l)
+	sort.SliceStable(r.layouts, func(i, j int) bool {
+		return len(r.layouts[i].segments) > len(r.layouts[j].segments)
+	})
 }
 
 // Match finds a matching route and extracts params.
 func (r *Router) Match(path string) (PageFunc, map[string]string) {
-	segs := splitPath(path)
-	for _, route := range r.routes {
-		if params, ok := matchRoute(route, segs); ok {
-			return route.Page, params
+	route, params := r.Lookup(path)
+	if route == nil {
+		return nil, nil
+	}
+	return route.Page, params
+}
+
+// Lookup finds the page route matching path and extracts its params.
+func (r *Router) Lookup(path string) (*Route, map[string]string) {
+	route, params, _ := r.LookupMethod(http.MethodGet, path)
+	return route, params
+}
+
+// LookupMethod finds the route answering method at path and extracts
+// its params, which are nil for a route without parameters. If routes
+// match path but none answers method, it returns a nil route and the
+// methods they allow.
+func (r *Router) LookupMethod(method, path string) (*Route, map[string]string, []string) {
+	path = strings.Trim(path, "/")
+	// A static route beats every other route matching the same path.
+	if route := r.static[path]; route != nil && route.handles(method) {
+		return route, nil, nil
+	}
+
+	var found *Route
+	var params map[string]string
+	var allowed []string
+	r.root.walk(path, nil, func(route *Route, values []capture) bool {
+		if route.handles(method) {
+			found, params = route, route.bind(values)
+			return true
+		}
+		for _, m := range route.methods() {
+			if !slices.Contains(allowed, m) {
+				allowed = append(allowed, m)
+			}
+		}
+		return false
+	})
+	if found != nil {
+		return found, params, nil
+	}
+	return nil, nil, allowed
+}
+
+// capture is the value of a parameter segment; set is false for an
+// optional segment the path leaves out.
+type capture struct {
+	value string
+	set   bool
+}
+
+// walk visits the routes matching path below n in order of precedence,
+// with the values of their parameter segments, until visit returns true.
+// path has no leading or trailing slash.
+func (n *node) walk(path string, values []capture, visit func(*Route, []capture) bool) bool {
+	seg, rest, _ := strings.Cut(path, "/")
+	if path == "" {
+		if n.route != nil && visit(n.route, values) {
+			return true
+		}
+	} else if child := n.static[seg]; child != nil && child.walk(rest, values, visit) {
+		return true
+	}
+
+	for _, e := range n.dynamic {
+		switch {
+		case e.seg.kind == segWildcard:
+			if e.child.route != nil && visit(e.child.route, append(values, capture{path, true})) {
+				return true
+			}
+		case path == "":
+			// Only optional segments may be left out.
+			if e.seg.kind == segOptional && e.child.walk("", append(values, capture{}), visit) {
+				return true
+			}
+		case e.seg.re != nil && !e.seg.re.MatchString(seg):
+		case e.child.walk(rest, append(values, capture{seg, true}), visit):
+			return true
+		}
+	}
+	return false
+}
+
+// bind names the parameter values of a match.
+func (r *Route) bind(values []capture) map[string]string {
+	params := make(map[string]string, len(values))
+	for i, v := range values {
+		if v.set {
+			params[r.params[i]] = v.value
+		}
+	}
+	return params
+}
+
+// RouteInfo describes a registered route; see Router.Routes.
+type RouteInfo struct {
+	Pattern string
+	Name    string
+	Methods []string // Methods answered, e.g. GET, HEAD, POST
+	Layouts []string // Prefixes of the layouts wrapping the page, outermost first
+}
+
+// Routes lists the registered routes in order of precedence. Layouts
+// lists the layouts that wrap every path of the route, leaving out those
+// that cover only some of them (such as "/docs/api" for "/docs/:page").
+func (r *Router) Routes() []RouteInfo {
+	infos := make([]RouteInfo, len(r.routes))
+	for i, route := range r.routes {
+		info := RouteInfo{Pattern: route.Pattern, Name: route.Name, Methods: route.methods()}
+		if route.Page != nil && !route.layoutOff {
+			for j := len(r.layouts) - 1; j >= 0; j-- {
+				l := r.layouts[j]
+				if coversRoute(l.segments, route.segments) && !slices.Contains(route.noLayout, l.prefix) {
+					info.Layouts = append(info.Layouts, l.prefix)
+				}
+			}
 		}
+		infos[i] = info
 	}
-	return nil, nil
+	return infos
 }
 
-// GetLayouts returns all matching layouts for a path.
+// coversRoute reports whether a layout prefix matches every path a
+// route with segments segs matches.
+func coversRoute(prefix, segs []segment) bool {
+	if len(prefix) > len(segs) {
+		return false
+	}
+	for i, seg := range prefix {
+		switch {
+		case seg.kind == segStatic:
+			if segs[i].kind != segStatic || segs[i].value != seg.value {
+				return false
+			}
+		case segs[i].kind == segOptional || segs[i].kind == segWildcard:
+			return false // Absent from some paths
+		case seg.re != nil && segs[i].kind == segStatic && !seg.re.MatchString(segs[i].value):
+			return false
+		}
+	}
+	return true
+}
+
+// GetLayouts returns the layouts covering path, innermost first: apply
+// them in order, each wrapping the result of the previous one.
 func (r *Router) GetLayouts(path string) []LayoutFunc {
+	return r.LayoutsFor(nil, path)
+}
+
+// LayoutsFor is GetLayouts without the layouts route opts out of.
+func (r *Router) LayoutsFor(route *Route, path string) []LayoutFunc {
+	if route != nil && route.layoutOff {
+		return nil
+	}
+	segs := splitPath(path)
 	var layouts []LayoutFunc
-	for prefix, layout := range r.layouts {
-		if strings.HasPrefix(path, prefix) {
-			layouts = append(layouts, layout)
+	for _, l := range r.layouts {
+		if matchPrefix(l.segments, segs) && (route == nil || !slices.Contains(route.noLayout, l.prefix)) {
+			layouts = append(layouts, l.fn)
 		}
 	}
 	return layouts
 }
 
-func parsePattern(pattern string) ([]string, []string) {
-	segs := splitPath(pattern)
-	params := make([]string, len(segs))
-	for i, seg := range segs {
-		if len(seg) > 0 && seg[0] == ':' {
-			params[i] = seg[1:]
-			segs[i] = ""
+// matchPrefix reports whether prefix matches the leading segments of segs.
+func matchPrefix(prefix []segment, segs []string) bool {
+	if len(prefix) > len(segs) {
+		return false
+	}
+	for i, seg := range prefix {
+		switch {
+		case seg.kind == segStatic && seg.value != segs[i]:
+			return false
+		case seg.re != nil && !seg.re.MatchString(segs[i]):
+			return false
 		}
 	}
-	return segs, params
+	return true
 }
 
-func splitPath(path string) []string {
-	path = strings.Trim(path, "/")
-	if path == "" {
-		return nil
+// cleanPrefix normalizes a layout prefix to "/a/b" form.
+func cleanPrefix(prefix string) string {
+	return "/" + strings.Trim(prefix, "/")
+}
+
+func parsePattern(pattern string) []segment {
+	parts := splitPath(pattern)
+	segs := make([]segment, len(parts))
+	for i, part := range parts {
+		seg, err := parseSegment(part)
+		if err == nil {
+			switch {
+			case seg.kind == segWildcard && i != len(parts)-1:
+				err = fmt.Errorf("wildcard %q must be the last segment", part)
+			case i > 0 && segs[i-1].kind == segOptional && seg.kind != segOptional && seg.kind != segWildcard:
+				err = fmt.Errorf("segment %q follows an optional segment", part)
+			}
+		}
+		if err != nil {
+			panic(fmt.Sprintf("forge: route %q: %v", pattern, err))
+		}
+		segs[i] = seg
 	}
-	return strings.Split(path, "/")
+	return segs
 }
 
-func matchRoute(route *Route, segs []string) (map[string]string, bool) {
-	if len(route.segments) != len(segs) {
-		if len(route.segments) == 0 && len(segs) == 0 {
-			return map[string]string{}, true
+func parseSegment(part string) (segment, error) {
+	if part == "" {
+		return segment{kind: segStatic}, nil
+	}
+	switch part[0] {
+	case '*':
+		name := part[1:]
+		if name == "" {
+			name = "*"
+		}
+		return segment{kind: segWildcard, value: name}, nil
+	case ':':
+	default:
+		return segment{kind: segStatic, value: part}, nil
+	}
+
+	name, constraint, typed := strings.Cut(part[1:], "<")
+	optional := false
+	if typed {
+		if constraint, optional = strings.CutSuffix(constraint, ">?"); !optional {
+			var ok bool
+			if constraint, ok = strings.CutSuffix(constraint, ">"); !ok {
+				return segment{}, fmt.Errorf("unclosed constraint in %q", part)
+			}
+		}
+	} else {
+		name, optional = strings.CutSuffix(name, "?")
+	}
+	if name == "" {
+		return segment{}, fmt.Errorf("unnamed parameter %q", part)
+	}
+
+	seg := segment{kind: segParam, value: name}
+	if typed {
+		expr, ok := constraints[constraint]
+		if !ok {
+			expr = constraint
 		}
-		return nil, false
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			return segment{}, fmt.Errorf("constraint of %q: %v", part, err)
+		}
+		seg.kind, seg.re = segTyped, re
+	}
+	if optional {
+		seg.kind = segOptional
 	}
-	params := make(map[string]string)
-	for i, seg := range route.segments {
-		if route.params[i] != "" {
-			params[route.params[i]] = segs[i]
-		} else if seg != segs[i] {
-			return nil, false
+	return seg, nil
+}
+
+// precedes reports whether a route with segments a is tried before one
+// with segments b.
+func precedes(a, b []segment) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		ka, kb := segEnd, segEnd
+		if i < len(a) {
+			ka = a[i].kind
+		}
+		if i < len(b) {
+			kb = b[i].kind
+		}
+		if ka != kb {
+			return ka < kb
 		}
 	}
-	return params, true
+	return false
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
 }
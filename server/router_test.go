@@ -0,0 +1,220 @@
+package server
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/Shravanthh/forge/ctx"
+	"github.com/Shravanthh/forge/ui"
+)
+
+func page(*ctx.Context) ui.UI { return ui.Fragment() }
+
+func TestRouterLookup(t *testing.T) {
+	r := NewRouter()
+	for _, pattern := range []string{
+		"/",
+		"/about",
+		"/user/me",
+		"/user/:id",
+		"/user/:id<int>",
+		"/user/:id<int>/posts",
+		"/user/:name/settings",
+		"/archive/:year?/:month?",
+		"/archive/latest",
+		"/files/*path",
+		"/files/readme",
+		"/docs/:section/*rest",
+		"/item/:code<[a-z]{3}>",
+		"/item/:uuid<uuid>",
+		"/item/:n<uint>",
+		"/tag/:tag?",
+		"/opt/:a<int>?",
+	} {
+		r.Add(pattern, page)
+	}
+
+	tests := []struct {
+		path    string
+		pattern string // "" for no match
+		params  map[string]string
+	}{
+		{"/", "/", nil},
+		{"", "/", nil},
+		{"/about", "/about", nil},
+		{"/about/", "/about", nil},
+		{"/missing", "", nil},
+
+		// Static beats constrained beats plain parameters.
+		{"/user/me", "/user/me", nil},
+		{"/user/42", "/user/:id<int>", map[string]string{"id": "42"}},
+		{"/user/-7", "/user/:id<int>", map[string]string{"id": "-7"}},
+		{"/user/bob", "/user/:id", map[string]string{"id": "bob"}},
+		{"/user/42/posts", "/user/:id<int>/posts", map[string]string{"id": "42"}},
+		{"/user/bob/posts", "", nil},
+		{"/user/42/settings", "/user/:name/settings", map[string]string{"name": "42"}},
+		{"/user/bob/settings", "/user/:name/settings", map[string]string{"name": "bob"}},
+
+		// Optional segments.
+		{"/archive", "/archive/:year?/:month?", map[string]string{}},
+		{"/archive/2024", "/archive/:year?/:month?", map[string]string{"year": "2024"}},
+		{"/archive/2024/05", "/archive/:year?/:month?", map[string]string{"year": "2024", "month": "05"}},
+		{"/archive/latest", "/archive/latest", nil},
+		{"/archive/2024/05/01", "", nil},
+		{"/tag", "/tag/:tag?", map[string]string{}},
+		{"/tag/go", "/tag/:tag?", map[string]string{"tag": "go"}},
+		{"/opt", "/opt/:a<int>?", map[string]string{}},
+		{"/opt/3", "/opt/:a<int>?", map[string]string{"a": "3"}},
+		{"/opt/x", "", nil},
+
+		// Wildcards match the rest of the path, even if empty.
+		{"/files", "/files/*path", map[string]string{"path": ""}},
+		{"/files/readme", "/files/readme", nil},
+		{"/files/a/b/c.txt", "/files/*path", map[string]string{"path": "a/b/c.txt"}},
+		{"/docs/guide", "/docs/:section/*rest", map[string]string{"section": "guide", "rest": ""}},
+		{"/docs/guide/a/b", "/docs/:section/*rest", map[string]string{"section": "guide", "rest": "a/b"}},
+
+		// Constraints.
+		{"/item/abc", "/item/:code<[a-z]{3}>", map[string]string{"code": "abc"}},
+		{"/item/abcd", "", nil},
+		{"/item/123", "/item/:n<uint>", map[string]string{"n": "123"}},
+		{"/item/-1", "", nil},
+		{"/item/0f8fad5b-d9cb-469f-a165-70867728950e", "/item/:uuid<uuid>", map[string]string{"uuid": "0f8fad5b-d9cb-469f-a165-70867728950e"}},
+	}
+	for _, tt := range tests {
+		route, params := r.Lookup(tt.path)
+		switch {
+		case tt.pattern == "" && route != nil:
+			t.Errorf("Lookup(%q) = %q, want no match", tt.path, route.Pattern)
+		case tt.pattern == "":
+		case route == nil:
+			t.Errorf("Lookup(%q) = no match, want %q", tt.path, tt.pattern)
+		case route.Pattern != tt.pattern:
+			t.Errorf("Lookup(%q) = %q, want %q", tt.path, route.Pattern, tt.pattern)
+		case !maps.Equal(params, tt.params):
+			t.Errorf("Lookup(%q) params = %v, want %v", tt.path, params, tt.params)
+		}
+	}
+}
+
+// Precedence, not registration order, picks the route.
+func TestRouterOrderIndependent(t *testing.T) {
+	patterns := []string{"/a/*rest", "/a/:x?", "/a/:x", "/a/:x<int>", "/a/b"}
+	want := map[string]string{
+		"/a/b":   "/a/b",
+		"/a/1":   "/a/:x<int>",
+		"/a/c":   "/a/:x",
+		"/a":     "/a/:x?",
+		"/a/c/d": "/a/*rest",
+	}
+	for _, order := range [][]string{patterns, {patterns[4], patterns[3], patterns[2], patterns[1], patterns[0]}} {
+		r := NewRouter()
+		for _, p := range order {
+			r.Add(p, page)
+		}
+		for path, pattern := range want {
+			if route, _ := r.Lookup(path); route == nil || route.Pattern != pattern {
+				t.Errorf("order %v: Lookup(%q) = %v, want %q", order, path, route, pattern)
+			}
+		}
+		var got []string
+		for _, info := range r.Routes() {
+			got = append(got, info.Pattern)
+		}
+		if want := []string{"/a/b", "/a/:x<int>", "/a/:x", "/a/:x?", "/a/*rest"}; !slices.Equal(got, want) {
+			t.Errorf("order %v: Routes() = %v, want %v", order, got, want)
+		}
+	}
+}
+
+func TestRouterLookupMethod(t *testing.T) {
+	r := NewRouter()
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	r.Add("/items/:id", page)
+	r.AddHandler(http.MethodPost, "/items/:id", ok)
+	r.AddHandler(http.MethodDelete, "/items/:id<int>", ok)
+
+	if route, _, _ := r.LookupMethod(http.MethodDelete, "/items/5"); route == nil || route.Pattern != "/items/:id<int>" {
+		t.Errorf("DELETE /items/5 = %v", route)
+	}
+	// The constrained route has no page, so GET falls through to the plain one.
+	if route, params, _ := r.LookupMethod(http.MethodGet, "/items/5"); route == nil || route.Pattern != "/items/:id" || params["id"] != "5" {
+		t.Errorf("GET /items/5 = %v %v", route, params)
+	}
+	if route, _, allowed := r.LookupMethod(http.MethodPut, "/items/5"); route != nil || !slices.Equal(allowed, []string{http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodPost}) {
+		t.Errorf("PUT /items/5 = %v, allowed %v", route, allowed)
+	}
+}
+
+func TestRouterConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string // The last one must panic
+	}{
+		{"same shape", []string{"/a/:x", "/a/:y"}},
+		{"same constraint", []string{"/a/:x<int>", "/a/:y<int>"}},
+		{"same optional", []string{"/a/:x?", "/a/:y?"}},
+		{"same wildcard", []string{"/a/*x", "/a/*y"}},
+		{"page twice", []string{"/a/:x", "/a/:x"}},
+		{"trailing slash", []string{"/a", "/a/"}},
+		{"overlapping constraints", []string{"/a/:id<int>", "/a/:n<[0-9]+>"}},
+		{"named and regexp", []string{"/a/:s<alpha>", "/a/:s<[a-z]+>"}},
+		{"case folded", []string{"/a/:s<abc>", "/a/:s<(?i)ABC>"}},
+		{"overlap before different tails", []string{"/a/:id<int>/x", "/a/:n<uint>/y"}},
+		{"overlap after shared prefix", []string{"/a/:p/:id<int>", "/a/:q/:n<uint>"}},
+		{"overlapping optional constraints", []string{"/a/:x<int>?", "/a/:y<uint>?"}},
+		{"malformed constraint", []string{"/a/:x<int"}},
+		{"invalid regexp", []string{"/a/:x<[>"}},
+		{"wildcard not last", []string{"/a/*x/b"}},
+		{"segment after optional", []string{"/a/:x?/b"}},
+		{"unnamed parameter", []string{"/a/:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter()
+			last := len(tt.patterns) - 1
+			for _, p := range tt.patterns[:last] {
+				r.Add(p, page)
+			}
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Add(%q) after %v did not panic", tt.patterns[last], tt.patterns[:last])
+				}
+				if len(r.Routes()) != last {
+					t.Errorf("a rejected route was registered: %v", r.Routes())
+				}
+			}()
+			r.Add(tt.patterns[last], page)
+		})
+	}
+}
+
+func TestRouterNoConflict(t *testing.T) {
+	tests := [][]string{
+		{"/a/:id<int>", "/a/:s<alpha>", "/a/:u<uuid>"},
+		{"/a/:x", "/a/:x<int>", "/a/:x?", "/a/*rest", "/a/b"},
+		{"/a/:x<[a-z]{3}>", "/a/:x<[a-z]{4}>"},
+		{"/a/:id<int>/x", "/b/:n<uint>/x"},
+		{"/a/:id<int>", "/a/:id<int>/b"},
+		{"/a/:x<int>?", "/a/:y<int>"},
+	}
+	for _, patterns := range tests {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					t.Errorf("%v: %v", patterns, err)
+				}
+			}()
+			r := NewRouter()
+			for _, p := range patterns {
+				r.Add(p, page)
+			}
+		}()
+	}
+	// An endpoint and a page share a pattern.
+	r := NewRouter()
+	r.Add("/a/:x", page)
+	r.AddHandler(http.MethodPost, "/a/:x", http.NotFoundHandler())
+}
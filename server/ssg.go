@@ -1,10 +1,10 @@
 package server
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 
-	"github.com/Shravanthh/forge/ctx"
 	"github.com/Shravanthh/forge/render"
 	"github.com/Shravanthh/forge/ui"
 )
@@ -16,28 +16,30 @@ type StaticPage struct {
 }
 
 // GenerateStatic generates static HTML files for the given pages.
-// Output files are written to the outDir directory.
+// Output files are written to the outDir directory, plus 404.html if a
+// NotFound page is set. It fails if a page links to an unknown route
+// name (see App.URL).
 func (a *App) GenerateStatic(outDir string, pages []StaticPage) error {
+	if err := a.router.CheckNames(); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(outDir, 0755); err != nil {
 		return err
 	}
 
 	for _, sp := range pages {
-		page, _ := a.router.Match(sp.Path)
-		if page == nil {
+		m := a.resolve(sp.Path)
+		if m == nil {
 			continue
 		}
 
-		c := ctx.New()
-		c.Params = sp.Params
-		ui.ResetEventCounter()
-		content := page(c)
-
-		for _, layout := range a.router.GetLayouts(sp.Path) {
-			content = layout(c, content)
+		c := a.newContext()
+		c.Params = m.params
+		for k, v := range sp.Params {
+			c.Params[k] = v
 		}
-
-		html := wrapHTMLStatic(render.HTML(content))
+		ui.ResetEventCounter(c)
+		content := m.page(c)
 
 		outPath := filepath.Join(outDir, sp.Path)
 		if sp.Path == "/" {
@@ -47,24 +49,47 @@ func (a *App) GenerateStatic(outDir string, pages []StaticPage) error {
 		}
 
 		os.MkdirAll(filepath.Dir(outPath), 0755)
-		if err := os.WriteFile(outPath, []byte(html), 0644); err != nil {
+		if err := writeStaticFile(outPath, content); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	// Static hosts (GitHub Pages, Netlify, S3, ...) serve 404.html for
+	// missing paths.
+	if page := a.notFoundPage("/"); page != nil {
+		c := a.newContext()
+		ui.ResetEventCounter(c)
+		if err := writeStaticFile(filepath.Join(outDir, "404.html"), page(c)); err != nil {
+			return err
+		}
+	}
+	if err := a.copyAssets(outDir); err != nil {
+		return err
+	}
+	// Every page has rendered, so this also catches names used in pages.
+	return a.router.CheckNames()
 }
 
-func wrapHTMLStatic(body string) string {
-	return `<!DOCTYPE html>
-<html>
-<head>
-<meta charset="UTF-8">
-<meta name="viewport" content="width=device-width,initial-scale=1">
-<title>Forge App</title>
-<style>` + ui.GetCSS() + `</style>
-</head>
-<body>
-` + body + `
-</body>
-</html>`
+func writeStaticFile(path string, content ui.UI) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeHTMLStatic(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeHTMLStatic streams a page without the client runtime to w.
+func writeHTMLStatic(w io.Writer, content ui.UI) error {
+	io.WriteString(w, docStart)
+	io.WriteString(w, ui.GetCSS())
+	io.WriteString(w, "</style>\n</head>\n<body>\n")
+	if err := render.Write(w, content); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n</body>\n</html>")
+	return err
 }
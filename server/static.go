@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticDir is a file system mounted with App.Static.
+type staticDir struct {
+	prefix string // e.g. /assets, or "" at the root
+	fsys   fs.FS
+
+	mu     sync.Mutex
+	hashes map[string]staticHash // By file name, e.g. img/logo.png
+	used   map[string]bool       // Files linked with Asset, for GenerateStatic
+}
+
+// staticHash is the content hash of a file, valid while its size and
+// modification time are unchanged.
+type staticHash struct {
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
+// Static serves the files of fsys under prefix. fsys may be an embed.FS
+// or a directory:
+//
+//	//go:embed assets
+//	var assets embed.FS
+//
+//	sub, _ := fs.Sub(assets, "assets")
+//	app.Static("/assets", sub)
+//	app.Static("/media", os.DirFS("./media"))
+//
+// Link to files with c.Asset, which adds a content hash to the name
+// (/assets/logo.3f9a1b2c4d.png). Fingerprinted URLs are cached for a year
+// and never revalidated; plain URLs (/assets/logo.png) are revalidated
+// with an ETag. Range requests are supported, and a precompressed
+// variant next to a file (logo.svg.br, logo.svg.gz) is served to
+// clients that accept it. Requests for missing files fall through to
+// the routes.
+func (a *App) Static(prefix string, fsys fs.FS) {
+	a.static = append(a.static, &staticDir{
+		prefix: strings.TrimSuffix(cleanPrefix(prefix), "/"), // "" at the root
+		fsys:   fsys,
+		hashes: make(map[string]staticHash),
+		used:   make(map[string]bool),
+	})
+}
+
+// Asset returns the fingerprinted URL of the file name in a directory
+// mounted with Static. Directories are searched in the order they were
+// mounted; a name may start with the prefix to pick one
+// ("/media/intro.mp4"). An unknown file is logged and its name returned
+// unchanged.
+func (a *App) Asset(name string) string {
+	for _, d := range a.static {
+		file, ok := strings.CutPrefix(name, d.prefix+"/")
+		if !ok {
+			file = strings.TrimPrefix(name, "/")
+		}
+		hash, err := d.hash(file)
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		d.used[file] = true
+		d.mu.Unlock()
+		return a.base + d.prefix + "/" + fingerprint(file, hash)
+	}
+	log.Printf("forge: unknown asset %q", name)
+	return name
+}
+
+// fingerprint inserts hash before the extension of file.
+func fingerprint(file, hash string) string {
+	ext := path.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + hash + ext
+}
+
+// unfingerprint splits a fingerprinted file name into the original name
+// and the hash. ok is false if name carries no hash.
+func unfingerprint(name string) (file, hash string, ok bool) {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	i := strings.LastIndexByte(base, '.')
+	if i < 0 || len(base)-i-1 != 10 {
+		return "", "", false
+	}
+	hash = base[i+1:]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", "", false
+	}
+	return base[:i] + ext, hash, true
+}
+
+// hash returns the content hash of file, reading it only when it changed.
+func (d *staticDir) hash(file string) (string, error) {
+	if !fs.ValidPath(file) {
+		return "", fs.ErrInvalid
+	}
+	info, err := fs.Stat(d.fsys, file)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fs.ErrNotExist
+	}
+	d.mu.Lock()
+	h, ok := d.hashes[file]
+	d.mu.Unlock()
+	if ok && h.size == info.Size() && h.modTime.Equal(info.ModTime()) {
+		return h.hash, nil
+	}
+
+	data, err := fs.ReadFile(d.fsys, file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	h = staticHash{hash: hex.EncodeToString(sum[:5]), size: info.Size(), modTime: info.ModTime()}
+	d.mu.Lock()
+	d.hashes[file] = h
+	d.mu.Unlock()
+	return h.hash, nil
+}
+
+// serve answers a GET or HEAD request for name, relative to the prefix.
+// It reports false if there is no such file.
+func (d *staticDir) serve(w http.ResponseWriter, r *http.Request, name string) bool {
+	file, want, fingerprinted := unfingerprint(name)
+	if !fingerprinted {
+		file = name
+	}
+	hash, err := d.hash(file)
+	if err != nil {
+		if !fingerprinted {
+			return false
+		}
+		// A file whose name merely looks fingerprinted.
+		file, fingerprinted = name, false
+		if hash, err = d.hash(file); err != nil {
+			return false
+		}
+	}
+
+	h := w.Header()
+	if fingerprinted && want == hash {
+		h.Set("Cache-Control", assetCacheControl)
+	} else {
+		// Plain URLs, and fingerprints from an older deploy, revalidate.
+		h.Set("Cache-Control", "no-cache")
+	}
+	h.Set("Vary", "Accept-Encoding")
+
+	// Ranges address the identity encoding, so only whole-file
+	// requests get a precompressed variant.
+	served, encoding := file, ""
+	if r.Header.Get("Range") == "" {
+		accept := r.Header.Get("Accept-Encoding")
+		for _, enc := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+			if acceptsEncoding(accept, enc.name) && d.exists(file+enc.ext) {
+				served, encoding = file+enc.ext, enc.name
+				break
+			}
+		}
+	}
+	etag := `"` + hash + `"`
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		etag = `"` + hash + "-" + encoding + `"`
+	}
+	h.Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	ctype := mime.TypeByExtension(path.Ext(file))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	h.Set("Content-Type", ctype)
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	f, err := d.fsys.Open(served)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return true
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return true
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return true
+		}
+		content = bytes.NewReader(data)
+	}
+	http.ServeContent(w, r, file, info.ModTime(), content)
+	return true
+}
+
+func (d *staticDir) exists(name string) bool {
+	info, err := fs.Stat(d.fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// serveStatic serves a request under a Static prefix. It reports false
+// if no mounted file matches.
+func (a *App) serveStatic(w http.ResponseWriter, r *http.Request) bool {
+	if len(a.static) == 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	for _, d := range a.static {
+		name, ok := strings.CutPrefix(r.URL.Path, d.prefix+"/")
+		if ok && d.serve(w, r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyAssets writes the files linked with Asset to outDir under their
+// fingerprinted names, with their precompressed variants.
+func (a *App) copyAssets(outDir string) error {
+	for _, d := range a.static {
+		d.mu.Lock()
+		files := make([]string, 0, len(d.used))
+		for file := range d.used {
+			files = append(files, file)
+		}
+		d.mu.Unlock()
+		sort.Strings(files)
+
+		for _, file := range files {
+			hash, err := d.hash(file)
+			if err != nil {
+				return err
+			}
+			dst := filepath.Join(outDir, filepath.FromSlash(d.prefix), filepath.FromSlash(fingerprint(file, hash)))
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			for _, ext := range []string{"", ".br", ".gz"} {
+				if ext != "" && !d.exists(file+ext) {
+					continue
+				}
+				data, err := fs.ReadFile(d.fsys, file+ext)
+				if err != nil {
+					return err
+				}
+				if err := os.WriteFile(dst+ext, data, 0644); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -1,55 +1,362 @@
 package server
 
 import (
+	"bufio"
+	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // UploadHandler handles file uploads.
 type UploadHandler func(filename string, data []byte) error
 
-// HandleUpload registers a file upload endpoint.
+// StreamHandler handles a single uploaded file without buffering it in memory.
+// It is called once per file in the request.
+type StreamHandler func(u *Upload) error
+
+// UploadPolicy configures limits for an upload endpoint.
+type UploadPolicy struct {
+	MaxSize      int64    // Maximum bytes per file
+	MaxFiles     int      // Maximum files per request
+	AllowedTypes []string // MIME types sniffed from content, e.g. "image/png" or "image/*"; empty allows all
+	Field        string   // Form field holding the files; empty accepts any field
+}
+
+// DefaultUploadPolicy accepts a single file of up to 32MB of any type.
+var DefaultUploadPolicy = UploadPolicy{MaxSize: 32 << 20, MaxFiles: 1}
+
+// Upload errors.
+var (
+	ErrUploadTooLarge   = errors.New("upload: file too large")
+	ErrUploadTooMany    = errors.New("upload: too many files")
+	ErrUploadType       = errors.New("upload: file type not allowed")
+	ErrUploadNameExists = errors.New("upload: could not find a free filename")
+)
+
+// Upload is a file being received by a StreamHandler.
+// Read from it, or use CopyTo, SaveTo or SaveTemp to consume it.
+type Upload struct {
+	Field       string // Form field name
+	Filename    string // Sanitized filename
+	ContentType string // MIME type sniffed from the content
+	r           io.Reader
+}
+
+// Read reads file content. It returns ErrUploadTooLarge once the policy
+// limit is exceeded.
+func (u *Upload) Read(p []byte) (int, error) { return u.r.Read(p) }
+
+// CopyTo streams the file into w.
+func (u *Upload) CopyTo(w io.Writer) (int64, error) { return io.Copy(w, u.r) }
+
+// SaveTo streams the file into dir under its sanitized name and returns
+// the path written. Existing files are never overwritten; a numeric suffix
+// is added instead (photo.png, photo-1.png, ...).
+func (u *Upload) SaveTo(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := createUnique(dir, u.Filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, u.r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// SaveTemp streams the file into a new temporary file and returns it
+// positioned at the start. The caller must close and remove it.
+func (u *Upload) SaveTemp() (*os.File, error) {
+	f, err := os.CreateTemp("", "forge-upload-*"+filepath.Ext(u.Filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, u.r); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
+
+type uploadEndpoint struct {
+	policy  UploadPolicy
+	handler StreamHandler
+}
+
+// HandleUpload registers a file upload endpoint using DefaultUploadPolicy.
+// The file is read into memory before the handler is called.
 func (a *App) HandleUpload(path string, handler UploadHandler) {
-	a.uploads[path] = handler
+	a.HandleUploadStream(path, DefaultUploadPolicy, func(u *Upload) error {
+		data, err := io.ReadAll(u)
+		if err != nil {
+			return err
+		}
+		return handler(u.Filename, data)
+	})
+}
+
+// HandleUploadStream registers a file upload endpoint that streams each
+// file to handler under the given policy. Zero policy fields fall back to
+// DefaultUploadPolicy.
+//
+//	app.HandleUploadStream("/upload/images", server.UploadPolicy{
+//	    MaxSize:      5 << 20,
+//	    MaxFiles:     10,
+//	    AllowedTypes: []string{"image/*"},
+//	}, server.StreamToDir("./uploads/images"))
+func (a *App) HandleUploadStream(path string, policy UploadPolicy, handler StreamHandler) {
+	if policy.MaxSize <= 0 {
+		policy.MaxSize = DefaultUploadPolicy.MaxSize
+	}
+	if policy.MaxFiles <= 0 {
+		policy.MaxFiles = DefaultUploadPolicy.MaxFiles
+	}
+	a.uploads[path] = &uploadEndpoint{policy: policy, handler: handler}
 }
 
-// ServeHTTP updated to handle uploads - see http.go
+// SocketUploadPolicy sets the limits of files uploaded over the session
+// socket with Element.OnUpload. MaxSize and AllowedTypes apply, with the
+// type sniffed from the content; zero fields fall back to
+// DefaultUploadPolicy. Files are held in memory until their handler runs,
+// and a session receives at most four at a time.
+//
+//	app.SocketUploadPolicy(server.UploadPolicy{MaxSize: 5 << 20, AllowedTypes: []string{"image/*"}})
+func (a *App) SocketUploadPolicy(policy UploadPolicy) {
+	if policy.MaxSize <= 0 {
+		policy.MaxSize = DefaultUploadPolicy.MaxSize
+	}
+	a.sessions.uploadPolicy = policy
+}
 
 // SaveToDir returns an UploadHandler that saves files to a directory.
+// Filenames are sanitized and never overwrite existing files.
 func SaveToDir(dir string) UploadHandler {
 	return func(filename string, data []byte) error {
-		os.MkdirAll(dir, 0755)
-		return os.WriteFile(filepath.Join(dir, filename), data, 0644)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		f, err := createUnique(dir, SanitizeFilename(filename))
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// StreamToDir returns a StreamHandler that streams files to a directory.
+// Filenames are sanitized and never overwrite existing files.
+func StreamToDir(dir string) StreamHandler {
+	return func(u *Upload) error {
+		_, err := u.SaveTo(dir)
+		return err
+	}
+}
+
+// SanitizeFilename reduces a client-supplied filename to a safe base name.
+// Directory components, control characters and characters reserved on
+// common filesystems are removed, and leading dots are stripped so the
+// result can never be "..", hidden, or empty.
+func SanitizeFilename(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	name = strings.Trim(b.String(), ". ")
+	if name == "" {
+		return "upload"
+	}
+
+	const maxLen = 200
+	if len(name) > maxLen {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:maxLen-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	return name
+}
+
+// createUnique creates name in dir, adding a numeric suffix if it exists.
+func createUnique(dir, name string) (*os.File, error) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 0; i < 1000; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = base + "-" + strconv.Itoa(i) + ext
+		}
+		f, err := os.OpenFile(filepath.Join(dir, candidate), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
 	}
+	return nil, ErrUploadNameExists
 }
 
-func handleUpload(w http.ResponseWriter, r *http.Request, handler UploadHandler) {
+func handleUpload(w http.ResponseWriter, r *http.Request, ep *uploadEndpoint) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", 405)
 		return
 	}
 
-	r.ParseMultipartForm(32 << 20) // 32MB max
-	file, header, err := r.FormFile("file")
+	p := ep.policy
+	// Allow some slack per file for multipart headers and boundaries.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(p.MaxFiles)*(p.MaxSize+4096))
+
+	mr, err := r.MultipartReader()
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 		return
 	}
-	defer file.Close()
 
-	data, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, err.Error(), 500)
-		return
+	var names []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		if part.FileName() == "" || (p.Field != "" && part.FormName() != p.Field) {
+			part.Close()
+			continue
+		}
+		if len(names) == p.MaxFiles {
+			part.Close()
+			uploadError(w, ErrUploadTooMany)
+			return
+		}
+
+		u, err := newUpload(part, p)
+		if err == nil {
+			err = ep.handler(u)
+		}
+		part.Close()
+		if err != nil {
+			uploadError(w, err)
+			return
+		}
+		names = append(names, u.Filename)
 	}
 
-	if err := handler(header.Filename, data); err != nil {
-		http.Error(w, err.Error(), 500)
+	if len(names) == 0 {
+		http.Error(w, "no file uploaded", 400)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
-	w.Write([]byte(`{"ok":true}`))
+	w.Write([]byte(`{"ok":true,"files":` + strconv.Itoa(len(names)) + `}`))
+}
+
+// newUpload sniffs the content type of a part and wraps it in a reader
+// enforcing the size limit.
+func newUpload(part *multipart.Part, p UploadPolicy) (*Upload, error) {
+	br := bufio.NewReaderSize(part, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	if !typeAllowed(contentType, p.AllowedTypes) {
+		return nil, ErrUploadType
+	}
+	return &Upload{
+		Field:       part.FormName(),
+		Filename:    SanitizeFilename(part.FileName()),
+		ContentType: contentType,
+		r:           &limitedReader{r: br, n: p.MaxSize},
+	}, nil
+}
+
+func typeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	for _, a := range allowed {
+		if a == contentType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(contentType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader is like io.LimitedReader but fails with ErrUploadTooLarge
+// instead of silently truncating.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrUploadTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrUploadTooLarge
+	}
+	return n, err
+}
+
+func uploadError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrUploadTooLarge), errors.As(err, &maxErr):
+		http.Error(w, ErrUploadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, ErrUploadType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, ErrUploadTooMany):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
 }
@@ -0,0 +1,989 @@
+//go:build js && wasm
+
+// Package wasmclient is the browser runtime of the WASM client. It
+// applies patches from the server, forwards events to it and hosts
+// client islands. server/wasm builds the default bundle from it; build
+// your own to include islands (see EnableIslands).
+package wasmclient
+
+import (
+	"encoding/json"
+	"syscall/js"
+)
+
+var ws js.Value
+var sessionID string
+
+// Subprotocols offered to the server, most compact first.
+const (
+	protocolBinary = "forge.v1.bin"
+	protocolJSON   = "forge.v1.json"
+)
+
+// internTable mirrors the server's per-connection string table.
+var internTable []string
+
+// patchTypes maps binary patch codes to patch types.
+var patchTypes = [...]string{1: "replace", 2: "attrs", 3: "text", 4: "insert", 5: "remove", 6: "move"}
+var uploadCounter int
+
+// uploadChunkSize is the number of bytes sent per upload_chunk message.
+const uploadChunkSize = 64 << 10
+
+type Patch struct {
+	Type   string            `json:"type"`
+	ID     string            `json:"id"`
+	Parent string            `json:"parent,omitempty"`
+	Before string            `json:"before,omitempty"`
+	HTML   string            `json:"html,omitempty"`
+	Attrs  map[string]string `json:"attrs,omitempty"`
+	Unset  []string          `json:"unset,omitempty"`
+	Text   string            `json:"text,omitempty"`
+}
+
+// Config is rendered into the page by the server as JSON in the
+// #forge-config script; WS moves with the app's base path.
+type Config struct {
+	Base string `json:"base"`
+	WS   string `json:"ws"`
+}
+
+// readConfig returns the rendered config, with defaults for a page
+// that has none.
+func readConfig() Config {
+	config := Config{WS: "/ws"}
+	if el := js.Global().Get("document").Call("getElementById", "forge-config"); el.Truthy() {
+		json.Unmarshal([]byte(el.Get("textContent").String()), &config)
+	}
+	return config
+}
+
+type Message struct {
+	Type     string  `json:"type"`
+	ID       string  `json:"id,omitempty"`
+	Patches  []Patch `json:"patches,omitempty"`
+	URL      string  `json:"url,omitempty"`
+	Filename string  `json:"filename,omitempty"`
+}
+
+// Run starts the client and blocks forever. Call it from main.
+func Run() {
+	doc := js.Global().Get("document")
+
+	// Wait for DOM ready
+	if doc.Get("readyState").String() == "loading" {
+		done := make(chan struct{})
+		var cb js.Func
+		cb = js.FuncOf(func(this js.Value, args []js.Value) any {
+			cb.Release()
+			close(done)
+			return nil
+		})
+		doc.Call("addEventListener", "DOMContentLoaded", cb)
+		<-done
+	}
+
+	exposeClient()
+	if !js.Global().Get("forgeTestMode").Truthy() {
+		// Setup event delegation
+		setupEvents(doc)
+
+		// Setup lazy loading
+		setupLazyLoading(doc)
+
+		// Connect WebSocket
+		connect()
+	}
+	doc.Call("dispatchEvent", js.Global().Get("Event").New("forge:ready"))
+
+	// Keep alive
+	select {}
+}
+
+// exposeClient sets window.forgeClient, the hook used by the conformance
+// suite. server/client/forge.js exposes the same object.
+func exposeClient() {
+	client := js.Global().Get("Object").New()
+	client.Set("name", "wasm")
+	client.Set("apply", js.FuncOf(func(this js.Value, args []js.Value) any {
+		var patches []Patch
+		if json.Unmarshal([]byte(args[0].String()), &patches) == nil {
+			for _, p := range patches {
+				applyPatch(p)
+			}
+		}
+		return nil
+	}))
+	client.Set("applyBinary", js.FuncOf(func(this js.Value, args []js.Value) any {
+		frame := make([]byte, args[0].Length())
+		js.CopyBytesToGo(frame, args[0])
+		for _, p := range decodeFrame(frame) {
+			applyPatch(p)
+		}
+		return nil
+	}))
+	client.Set("reset", js.FuncOf(func(this js.Value, args []js.Value) any {
+		internTable = nil
+		return nil
+	}))
+	js.Global().Set("forgeClient", client)
+}
+
+func setupEvents(doc js.Value) {
+	// Click events
+	doc.Call("addEventListener", "click", js.FuncOf(func(this js.Value, args []js.Value) any {
+		evt := args[0]
+		target := evt.Get("target")
+		
+		el := closest(target, "[data-forge-click]")
+		if !el.IsNull() {
+			if el.Get("type").String() != "checkbox" {
+				evt.Call("preventDefault")
+			}
+			dispatch(el, el.Get("dataset").Get("forgeClick").String(), "")
+		}
+		return nil
+	}))
+
+	// Input events
+	doc.Call("addEventListener", "input", js.FuncOf(func(this js.Value, args []js.Value) any {
+		target := args[0].Get("target")
+		id := target.Get("dataset").Get("forgeInput")
+		if !id.IsUndefined() {
+			dispatch(target, id.String(), target.Get("value").String())
+		}
+		return nil
+	}))
+
+	// Keydown events
+	doc.Call("addEventListener", "keydown", js.FuncOf(func(this js.Value, args []js.Value) any {
+		evt := args[0]
+		if evt.Get("key").String() == "Enter" {
+			target := evt.Get("target")
+			id := target.Get("dataset").Get("forgeKeydown")
+			if !id.IsUndefined() {
+				evt.Call("preventDefault")
+				dispatch(target, id.String(), target.Get("value").String())
+			}
+		}
+		return nil
+	}))
+
+	// Change events
+	doc.Call("addEventListener", "change", js.FuncOf(func(this js.Value, args []js.Value) any {
+		target := args[0].Get("target")
+		if upload := target.Get("dataset").Get("forgeUpload"); !upload.IsUndefined() {
+			uploadFiles(upload.String(), target)
+		}
+		id := target.Get("dataset").Get("forgeChange")
+		if !id.IsUndefined() {
+			val := target.Get("value").String()
+			if target.Get("type").String() == "checkbox" {
+				val = "false"
+				if target.Get("checked").Bool() {
+					val = "true"
+				}
+			}
+			dispatch(target, id.String(), val)
+		}
+		return nil
+	}))
+
+	// Scroll events (debounced)
+	var scrollTimer js.Value
+	doc.Call("addEventListener", "scroll", js.FuncOf(func(this js.Value, args []js.Value) any {
+		target := args[0].Get("target")
+		id := target.Get("dataset").Get("forgeScroll")
+		if !id.IsUndefined() {
+			if !scrollTimer.IsUndefined() {
+				js.Global().Call("clearTimeout", scrollTimer)
+			}
+			scrollTimer = js.Global().Call("setTimeout", js.FuncOf(func(this js.Value, args []js.Value) any {
+				sendScroll(id.String(), target.Get("scrollTop").Int())
+				return nil
+			}), 100)
+		}
+		return nil
+	}), true)
+
+	// Drag and drop
+	var dragID string
+	doc.Call("addEventListener", "dragstart", js.FuncOf(func(this js.Value, args []js.Value) any {
+		target := args[0].Get("target")
+		id := target.Get("dataset").Get("forgeDrag")
+		if !id.IsUndefined() {
+			dragID = id.String()
+		}
+		return nil
+	}))
+
+	doc.Call("addEventListener", "dragover", js.FuncOf(func(this js.Value, args []js.Value) any {
+		evt := args[0]
+		target := evt.Get("target")
+		if closest(target, "[data-forge-dropzone]").Truthy() {
+			evt.Call("preventDefault")
+		}
+		return nil
+	}))
+
+	doc.Call("addEventListener", "drop", js.FuncOf(func(this js.Value, args []js.Value) any {
+		evt := args[0]
+		evt.Call("preventDefault")
+		target := closest(evt.Get("target"), "[data-forge-dropzone]")
+		if !target.IsNull() {
+			id := target.Get("dataset").Get("forgeDrop")
+			if !id.IsUndefined() && dragID != "" {
+				sendDrop(id.String(), dragID)
+				dragID = ""
+			}
+		}
+		return nil
+	}))
+}
+
+func closest(el js.Value, selector string) js.Value {
+	if el.IsNull() || el.IsUndefined() {
+		return js.Null()
+	}
+	return el.Call("closest", selector)
+}
+
+func connect() {
+	loc := js.Global().Get("location")
+	proto := "ws:"
+	if loc.Get("protocol").String() == "https:" {
+		proto = "wss:"
+	}
+	host := loc.Get("host").String()
+	path := js.Global().Call("encodeURIComponent", loc.Get("pathname")).String()
+	query := js.Global().Call("encodeURIComponent", loc.Get("search")).String()
+	url := proto + "//" + host + readConfig().WS + "?path=" + path + "&query=" + query
+	if sessionID != "" {
+		url += "&session=" + sessionID
+	}
+
+	protocols := js.Global().Get("Array").New(protocolBinary, protocolJSON)
+	ws = js.Global().Get("WebSocket").New(url, protocols)
+	ws.Set("binaryType", "arraybuffer")
+	internTable = nil
+
+	ws.Set("onmessage", js.FuncOf(func(this js.Value, args []js.Value) any {
+		raw := args[0].Get("data")
+		if raw.Type() != js.TypeString {
+			buf := js.Global().Get("Uint8Array").New(raw)
+			frame := make([]byte, buf.Length())
+			js.CopyBytesToGo(frame, buf)
+			patches := decodeFrame(frame)
+			if patches == nil {
+				// The server has committed strings this client lacks;
+				// a new connection starts both tables afresh.
+				ws.Call("close")
+				return nil
+			}
+			for _, p := range patches {
+				applyPatch(p)
+			}
+			return nil
+		}
+		data := raw.String()
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil
+		}
+
+		if msg.Type == "session" {
+			sessionID = msg.ID
+		} else if msg.Type == "patch" {
+			for _, p := range msg.Patches {
+				applyPatch(p)
+			}
+		} else if msg.Type == "download" {
+			download(msg.URL, msg.Filename)
+		} else if msg.Type == "reload" {
+			js.Global().Get("location").Call("reload")
+		} else if msg.Type == "redirect" {
+			js.Global().Get("location").Call("assign", msg.URL)
+		} else if msg.Type == "url" {
+			loc := js.Global().Get("location")
+			history := js.Global().Get("history")
+			history.Call("replaceState", history.Get("state"), "", loc.Get("pathname").String()+msg.URL+loc.Get("hash").String())
+		}
+		return nil
+	}))
+
+	ws.Set("onclose", js.FuncOf(func(this js.Value, args []js.Value) any {
+		// Reconnect after 1 second
+		js.Global().Call("setTimeout", js.FuncOf(func(this js.Value, args []js.Value) any {
+			connect()
+			return nil
+		}), 1000)
+		return nil
+	}))
+}
+
+// localEvent runs events inside client islands; see EnableIslands.
+var localEvent func(el js.Value, id, value string) bool
+
+// dispatch handles an event from el locally if it belongs to a client
+// island, and sends it to the server otherwise.
+func dispatch(el js.Value, id, value string) {
+	if localEvent != nil && localEvent(el, id, value) {
+		return
+	}
+	if localEvent == nil && !closest(el, "[data-forge-island]").IsNull() {
+		// The server has no handler for it either.
+		js.Global().Get("console").Call("error", "forge: client islands are inert in this bundle; build one that calls wasmclient.EnableIslands (see docs/islands.md)")
+		return
+	}
+	send(id, value)
+}
+
+func send(id, value string) {
+	if ws.Get("readyState").Int() != 1 {
+		return
+	}
+	msg := map[string]string{"type": "event", "id": id, "value": value}
+	data, _ := json.Marshal(msg)
+	ws.Call("send", string(data))
+}
+
+func sendScroll(id string, scrollTop int) {
+	if ws.Get("readyState").Int() != 1 {
+		return
+	}
+	msg := map[string]any{"type": "scroll", "id": id, "scrollTop": scrollTop}
+	data, _ := json.Marshal(msg)
+	ws.Call("send", string(data))
+}
+
+func sendDrop(id, dragID string) {
+	if ws.Get("readyState").Int() != 1 {
+		return
+	}
+	msg := map[string]string{"type": "drop", "id": id, "dragId": dragID}
+	data, _ := json.Marshal(msg)
+	ws.Call("send", string(data))
+}
+
+// decodeFrame decodes a binary patch frame (see server/protocol.go).
+// Unknown versions and malformed frames yield nil. Strings a frame adds
+// to the intern table are kept only if the whole frame decodes, as on
+// the server.
+func decodeFrame(b []byte) []Patch {
+	d := frameDecoder{b: b}
+	if d.byte() != 1 {
+		return nil
+	}
+	n := d.uvarint()
+	patches := make([]Patch, 0, n)
+	for i := uint64(0); i < n && d.ok(); i++ {
+		code := int(d.byte())
+		if code >= len(patchTypes) || patchTypes[code] == "" {
+			return nil
+		}
+		p := Patch{Type: patchTypes[code], ID: d.str()}
+		switch p.Type {
+		case "replace":
+			p.HTML = d.lit()
+		case "attrs":
+			count := d.uvarint()
+			p.Attrs = make(map[string]string, count)
+			for j := uint64(0); j < count && d.ok(); j++ {
+				k := d.str()
+				p.Attrs[k] = d.lit()
+			}
+			count = d.uvarint()
+			for j := uint64(0); j < count && d.ok(); j++ {
+				p.Unset = append(p.Unset, d.str())
+			}
+		case "text":
+			p.Text = d.lit()
+		case "insert":
+			p.Parent, p.Before, p.HTML = d.str(), d.str(), d.lit()
+		case "move":
+			p.Parent, p.Before = d.str(), d.str()
+		}
+		patches = append(patches, p)
+	}
+	if !d.ok() {
+		return nil
+	}
+	internTable = append(internTable, d.added...)
+	return patches
+}
+
+type frameDecoder struct {
+	b     []byte
+	pos   int
+	bad   bool
+	added []string // Strings new in this frame
+}
+
+func (d *frameDecoder) ok() bool { return !d.bad }
+
+func (d *frameDecoder) byte() byte {
+	if d.pos >= len(d.b) {
+		d.bad = true
+		return 0
+	}
+	d.pos++
+	return d.b[d.pos-1]
+}
+
+func (d *frameDecoder) uvarint() uint64 {
+	var x uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		c := d.byte()
+		x |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return x
+		}
+	}
+	d.bad = true
+	return 0
+}
+
+func (d *frameDecoder) lit() string {
+	n := int(d.uvarint())
+	if n < 0 || d.pos+n > len(d.b) {
+		d.bad = true
+		return ""
+	}
+	s := string(d.b[d.pos : d.pos+n])
+	d.pos += n
+	return s
+}
+
+func (d *frameDecoder) str() string {
+	switch tag := d.uvarint(); tag {
+	case 0:
+		s := d.lit()
+		d.added = append(d.added, s)
+		return s
+	case 1:
+		return d.lit()
+	default:
+		i := int(tag - 2)
+		if i < 0 {
+			d.bad = true
+			return ""
+		}
+		if i < len(internTable) {
+			return internTable[i]
+		}
+		if i -= len(internTable); i < len(d.added) {
+			return d.added[i]
+		}
+		d.bad = true
+		return ""
+	}
+}
+
+// download makes the browser fetch a one-time download URL.
+func download(url, filename string) {
+	doc := js.Global().Get("document")
+	a := doc.Call("createElement", "a")
+	a.Set("href", url)
+	a.Set("download", filename)
+	a.Get("style").Set("display", "none")
+	doc.Get("body").Call("appendChild", a)
+	a.Call("click")
+	a.Call("remove")
+}
+
+func sendJSON(msg any) {
+	if ws.Get("readyState").Int() != 1 {
+		return
+	}
+	data, _ := json.Marshal(msg)
+	ws.Call("send", string(data))
+}
+
+// uploadFiles streams every selected file of a file input to the server.
+func uploadFiles(id string, input js.Value) {
+	files := input.Get("files")
+	for i := 0; i < files.Length(); i++ {
+		uploadCounter++
+		uploadFile(id, "u"+itoa(uploadCounter), files.Index(i))
+	}
+	input.Set("value", "")
+}
+
+// uploadFile sends upload_start, then one upload_chunk per slice read
+// from the file, then upload_end. Chunks are read sequentially so the
+// server receives them in order.
+func uploadFile(id, uploadID string, file js.Value) {
+	size := file.Get("size").Int()
+	sendJSON(map[string]any{
+		"type": "upload_start", "id": id, "upload": uploadID,
+		"name": file.Get("name").String(), "size": size, "mime": file.Get("type").String(),
+	})
+
+	var next func(offset int)
+	next = func(offset int) {
+		if offset >= size {
+			sendJSON(map[string]any{"type": "upload_end", "upload": uploadID})
+			return
+		}
+		end := offset + uploadChunkSize
+		if end > size {
+			end = size
+		}
+		var cb js.Func
+		cb = js.FuncOf(func(this js.Value, args []js.Value) any {
+			cb.Release()
+			buf := js.Global().Get("Uint8Array").New(args[0])
+			data := make([]byte, buf.Length())
+			js.CopyBytesToGo(data, buf)
+			sendJSON(map[string]any{"type": "upload_chunk", "upload": uploadID, "data": data})
+			next(end)
+			return nil
+		})
+		file.Call("slice", offset, end).Call("arrayBuffer").Call("then", cb)
+	}
+	next(0)
+}
+
+func itoa(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	var b [20]byte
+	n := len(b)
+	for i > 0 {
+		n--
+		b[n] = byte('0' + i%10)
+		i /= 10
+	}
+	return string(b[n:])
+}
+
+func applyPatch(p Patch) {
+	doc := js.Global().Get("document")
+	el := doc.Call("querySelector", idSelector(p.ID))
+
+	switch p.Type {
+	case "replace":
+		if !el.IsNull() {
+			replaceNode(el, p.HTML)
+		} else if marker := textMarker(p.ID); !marker.IsNull() {
+			parent := marker.Get("parentNode")
+			parent.Call("insertBefore", parseHTML(p.HTML, parent), marker)
+			removeMarkedText(marker)
+		}
+	case "attrs":
+		if !el.IsNull() {
+			for k, v := range p.Attrs {
+				el.Call("setAttribute", k, v)
+				if boolProps[k] {
+					el.Set(k, true)
+				}
+			}
+			for _, k := range p.Unset {
+				el.Call("removeAttribute", k)
+				if boolProps[k] {
+					el.Set(k, false)
+				}
+			}
+		}
+	case "text":
+		// Text with siblings follows a <!--id--> marker; a lone text
+		// node is addressed through its parent element.
+		if marker := textMarker(p.ID); !marker.IsNull() {
+			setMarkedText(marker, p.Text)
+		} else if el = domParent(p.ID); !el.IsNull() {
+			el.Set("textContent", p.Text)
+		}
+	case "remove":
+		if !el.IsNull() {
+			el.Call("remove")
+		} else if marker := textMarker(p.ID); !marker.IsNull() {
+			removeMarkedText(marker)
+		}
+	case "insert":
+		parent := patchParent(p)
+		if !parent.IsNull() {
+			parent.Call("insertBefore", parseHTML(p.HTML, parent), childByID(parent, p.Before))
+		}
+	case "move":
+		parent := patchParent(p)
+		if !el.IsNull() && !parent.IsNull() {
+			parent.Call("insertBefore", el, childByID(parent, p.Before))
+		}
+	}
+}
+
+// boolProps are boolean attributes whose live DOM property can diverge
+// from the attribute after user interaction.
+var boolProps = map[string]bool{"checked": true, "selected": true}
+
+func byID(id string) js.Value {
+	return js.Global().Get("document").Call("querySelector", idSelector(id))
+}
+
+// idSelector returns a selector matching data-forge-id, escaping the ID
+// so keys with quotes or backslashes cannot break the selector.
+func idSelector(id string) string {
+	return "[data-forge-id=\"" + js.Global().Get("CSS").Call("escape", id).String() + "\"]"
+}
+
+// Namespaces of SVG and MathML elements.
+const (
+	nsSVG    = "http://www.w3.org/2000/svg"
+	nsMathML = "http://www.w3.org/1998/Math/MathML"
+)
+
+// parseHTML parses patch HTML into a DocumentFragment as children of
+// parent. Inside SVG or MathML the markup is parsed within an <svg> or
+// <math> wrapper, so <path> or <mi> get their namespace instead of
+// becoming unknown HTML elements.
+func parseHTML(html string, parent js.Value) js.Value {
+	doc := js.Global().Get("document")
+	tpl := doc.Call("createElement", "template")
+
+	wrapper := ""
+	if !parent.IsNull() && !parent.IsUndefined() && parent.Get("localName").String() != "foreignObject" {
+		switch parent.Get("namespaceURI").String() {
+		case nsSVG:
+			wrapper = "svg"
+		case nsMathML:
+			wrapper = "math"
+		}
+	}
+	if wrapper == "" {
+		tpl.Set("innerHTML", html)
+		return tpl.Get("content")
+	}
+
+	tpl.Set("innerHTML", "<"+wrapper+">"+html+"</"+wrapper+">")
+	root := tpl.Get("content").Get("firstChild")
+	frag := doc.Call("createDocumentFragment")
+	for !root.Get("firstChild").IsNull() {
+		frag.Call("appendChild", root.Get("firstChild"))
+	}
+	return frag
+}
+
+// replaceNode morphs target into html when it is a single element,
+// otherwise replaces it with the parsed nodes.
+func replaceNode(target js.Value, html string) {
+	frag := parseHTML(html, target.Get("parentNode"))
+	if frag.Get("childNodes").Length() == 1 && frag.Get("firstChild").Get("nodeType").Int() == 1 {
+		morph(target, frag.Get("firstChild"))
+		return
+	}
+	target.Call("replaceWith", frag)
+}
+
+// markerText escapes an ID the way the renderer writes it in a marker
+// comment.
+func markerText(id string) string {
+	var b []byte
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; c {
+		case '%':
+			b = append(b, "%25"...)
+		case '<':
+			b = append(b, "%3C"...)
+		case '>':
+			b = append(b, "%3E"...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// textMarker finds the <!--id--> comment preceding a text node.
+func textMarker(id string) js.Value {
+	parent := domParent(id)
+	if parent.IsNull() {
+		// Text of a root fragment sits directly in the body.
+		parent = js.Global().Get("document").Get("body")
+	}
+	kids := parent.Get("childNodes")
+	for i := 0; i < kids.Length(); i++ {
+		kid := kids.Index(i)
+		if kid.Get("nodeType").Int() == 8 && kid.Get("data").String() == markerText(id) {
+			return kid
+		}
+	}
+	return js.Null()
+}
+
+// setMarkedText sets the text following a marker, creating the text
+// node if it was rendered empty.
+func setMarkedText(marker js.Value, text string) {
+	next := marker.Get("nextSibling")
+	if !next.IsNull() && next.Get("nodeType").Int() == 3 {
+		next.Set("data", text)
+		return
+	}
+	node := js.Global().Get("document").Call("createTextNode", text)
+	marker.Get("parentNode").Call("insertBefore", node, next)
+}
+
+// removeMarkedText removes a marker and the text node it precedes.
+func removeMarkedText(marker js.Value) {
+	next := marker.Get("nextSibling")
+	if !next.IsNull() && next.Get("nodeType").Int() == 3 {
+		next.Call("remove")
+	}
+	marker.Call("remove")
+}
+
+// patchParent finds the parent element of an insert or move patch.
+func patchParent(p Patch) js.Value {
+	parent := domParent(p.ID)
+	if p.Parent != "" {
+		parent = closestByID(p.Parent)
+	}
+	if parent.IsNull() {
+		// Children of a root fragment sit directly in the body.
+		return js.Global().Get("document").Get("body")
+	}
+	return parent
+}
+
+// childByID returns the direct child of parent with the given data-forge-id,
+// or the marker comment of the text with that ID. Returns null (append
+// position) if id is empty or not found.
+func childByID(parent js.Value, id string) js.Value {
+	if id == "" {
+		return js.Null()
+	}
+	if el := parent.Call("querySelector", ":scope > "+idSelector(id)); !el.IsNull() {
+		return el
+	}
+	return textMarker(id)
+}
+
+// morph updates target in place to match the parsed element src.
+func morph(target, src js.Value) {
+	// Different tag or namespace - replace entirely
+	if target.Get("tagName").String() != src.Get("tagName").String() ||
+		target.Get("namespaceURI").String() != src.Get("namespaceURI").String() {
+		target.Call("replaceWith", src)
+		return
+	}
+
+	// Sync attributes
+	syncAttrs(target, src)
+
+	// Sync children
+	morphChildren(target, src)
+}
+
+func syncAttrs(target, src js.Value) {
+	srcAttrs := src.Get("attributes")
+	targetAttrs := target.Get("attributes")
+
+	// Build set of src attr names
+	srcNames := make(map[string]bool)
+	for i := 0; i < srcAttrs.Length(); i++ {
+		name := srcAttrs.Index(i).Get("name").String()
+		srcNames[name] = true
+	}
+
+	// Remove attrs not in src
+	toRemove := []string{}
+	for i := 0; i < targetAttrs.Length(); i++ {
+		name := targetAttrs.Index(i).Get("name").String()
+		if !srcNames[name] {
+			toRemove = append(toRemove, name)
+		}
+	}
+	for _, name := range toRemove {
+		target.Call("removeAttribute", name)
+	}
+
+	// Set attrs from src
+	for i := 0; i < srcAttrs.Length(); i++ {
+		attr := srcAttrs.Index(i)
+		name := attr.Get("name").String()
+		value := attr.Get("value").String()
+		if target.Call("getAttribute", name).String() != value {
+			target.Call("setAttribute", name, value)
+		}
+	}
+
+	// Handle input properties
+	if target.Get("tagName").String() == "INPUT" {
+		target.Set("checked", src.Call("hasAttribute", "checked").Bool())
+		if src.Call("hasAttribute", "value").Bool() {
+			target.Set("value", src.Call("getAttribute", "value").String())
+		}
+	}
+}
+
+func morphChildren(target, src js.Value) {
+	tKids := target.Get("childNodes")
+	sKids := src.Get("childNodes")
+	tLen := tKids.Length()
+	sLen := sKids.Length()
+
+	max := tLen
+	if sLen > max {
+		max = sLen
+	}
+
+	for i := 0; i < max; i++ {
+		var t, s js.Value
+		if i < tLen {
+			t = tKids.Index(i)
+		}
+		if i < sLen {
+			s = sKids.Index(i)
+		}
+
+		if s.IsUndefined() || s.IsNull() {
+			// Removing shifts the remaining target children down.
+			for tKids.Length() > sLen {
+				tKids.Index(tKids.Length() - 1).Call("remove")
+			}
+			return
+		}
+
+		if t.IsUndefined() || t.IsNull() {
+			target.Call("appendChild", s.Call("cloneNode", true))
+			continue
+		}
+
+		tType := t.Get("nodeType").Int()
+		sType := s.Get("nodeType").Int()
+
+		if tType != sType {
+			t.Call("replaceWith", s.Call("cloneNode", true))
+			continue
+		}
+
+		if tType == 3 || tType == 8 { // Text node or marker comment
+			if t.Get("data").String() != s.Get("data").String() {
+				t.Set("data", s.Get("data").String())
+			}
+			continue
+		}
+
+		if tType == 1 { // Element
+			morph(t, s.Call("cloneNode", true))
+		}
+	}
+}
+
+// domParent finds the element containing the node at path. Fragment
+// children have paths nested below their fragment's slot (0.2.1 for the
+// second node of a fragment at 0.2), which has no element of its own, so
+// the nearest rendered ancestor is used.
+func domParent(path string) js.Value {
+	return closestByID(parentPath(path))
+}
+
+// closestByID returns the element with the given data-forge-id, or that
+// of its nearest ancestor path. Returns null if none is rendered.
+func closestByID(path string) js.Value {
+	for {
+		if el := byID(path); !el.IsNull() {
+			return el
+		}
+		i := len(path) - 1
+		for i >= 0 && path[i] != '.' {
+			i--
+		}
+		if i < 0 {
+			return js.Null()
+		}
+		path = path[:i]
+	}
+}
+
+func parentPath(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '.' {
+			return path[:i]
+		}
+	}
+	return "0"
+}
+
+func setupLazyLoading(doc js.Value) {
+	// Intersection observer for lazy components
+	callback := js.FuncOf(func(this js.Value, args []js.Value) any {
+		entries := args[0]
+		for i := 0; i < entries.Length(); i++ {
+			entry := entries.Index(i)
+			if entry.Get("isIntersecting").Bool() {
+				target := entry.Get("target")
+				id := target.Get("dataset").Get("forgeVisible")
+				if !id.IsUndefined() {
+					send(id.String(), "")
+				}
+			}
+		}
+		return nil
+	})
+
+	opts := map[string]any{"threshold": 0.1}
+	optsJS := js.Global().Get("Object").New()
+	optsJS.Set("threshold", opts["threshold"])
+
+	observer := js.Global().Get("IntersectionObserver").New(callback, optsJS)
+
+	// Observe existing lazy elements
+	elements := doc.Call("querySelectorAll", "[data-forge-visible]")
+	for i := 0; i < elements.Length(); i++ {
+		observer.Call("observe", elements.Index(i))
+	}
+
+	// Observe new elements via MutationObserver
+	mutationCb := js.FuncOf(func(this js.Value, args []js.Value) any {
+		mutations := args[0]
+		for i := 0; i < mutations.Length(); i++ {
+			added := mutations.Index(i).Get("addedNodes")
+			for j := 0; j < added.Length(); j++ {
+				node := added.Index(j)
+				if node.Get("nodeType").Int() == 1 {
+					if !node.Get("dataset").Get("forgeVisible").IsUndefined() {
+						observer.Call("observe", node)
+					}
+					lazy := node.Call("querySelectorAll", "[data-forge-visible]")
+					for k := 0; k < lazy.Length(); k++ {
+						observer.Call("observe", lazy.Index(k))
+					}
+				}
+			}
+		}
+		return nil
+	})
+
+	mutationOpts := js.Global().Get("Object").New()
+	mutationOpts.Set("childList", true)
+	mutationOpts.Set("subtree", true)
+
+	mutationObserver := js.Global().Get("MutationObserver").New(mutationCb)
+	mutationObserver.Call("observe", doc.Get("body"), mutationOpts)
+
+	// Lazy images
+	imgCallback := js.FuncOf(func(this js.Value, args []js.Value) any {
+		entries := args[0]
+		imgObserver := args[1]
+		for i := 0; i < entries.Length(); i++ {
+			entry := entries.Index(i)
+			if entry.Get("isIntersecting").Bool() {
+				img := entry.Get("target")
+				src := img.Get("dataset").Get("src")
+				if !src.IsUndefined() {
+					img.Set("src", src.String())
+					img.Get("classList").Call("add", "loaded")
+					imgObserver.Call("unobserve", img)
+				}
+			}
+		}
+		return nil
+	})
+
+	imgObserver := js.Global().Get("IntersectionObserver").New(imgCallback, optsJS)
+	images := doc.Call("querySelectorAll", ".lazy-image")
+	for i := 0; i < images.Length(); i++ {
+		imgObserver.Call("observe", images.Index(i))
+	}
+}
@@ -0,0 +1,107 @@
+//go:build js && wasm
+
+package wasmclient
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/Shravanthh/forge/ctx"
+	"github.com/Shravanthh/forge/diff"
+	"github.com/Shravanthh/forge/ui"
+)
+
+// island is a client island mounted on its wrapper element.
+type island struct {
+	el          js.Value
+	name, props string
+	c           *ctx.Context
+	tree        ui.UI
+}
+
+// mounted holds islands by wrapper ID.
+var mounted = make(map[string]*island)
+
+// EnableIslands makes the client run the islands registered with
+// ui.RegisterIsland. Call it before Run in a bundle that imports the
+// package registering them:
+//
+//	package main
+//
+//	import (
+//	    "github.com/Shravanthh/forge/server/wasmclient"
+//	    _ "example.com/app/islands"
+//	)
+//
+//	func main() {
+//	    wasmclient.EnableIslands()
+//	    wasmclient.Run()
+//	}
+//
+// The default bundle leaves islands out to stay small.
+func EnableIslands() { localEvent = islandEvent }
+
+// islandEvent runs handler id of the island containing el, patches the
+// island and sends the calls it made to the server. It reports false if
+// el is not inside an island this bundle knows.
+func islandEvent(el js.Value, id, value string) bool {
+	root := closest(el, "[data-forge-island]")
+	if root.IsNull() {
+		return false
+	}
+	is := mountIsland(root)
+	if is == nil {
+		return false
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				js.Global().Get("console").Call("error", "forge: island "+is.name+" handler panic")
+			}
+		}()
+		if value != "" {
+			is.c.HandleWithValue(id, value)
+		} else {
+			is.c.Handle(id)
+		}
+	}()
+
+	tree := ui.RenderIsland(is.name, is.c)
+	path := root.Get("dataset").Get("forgeId").String() + ".0"
+	for _, p := range diff.DiffAt(is.tree, tree, path) {
+		applyPatch(Patch{
+			Type: string(p.Type), ID: p.ID, Parent: p.Parent, Before: p.Before,
+			HTML: p.HTML, Attrs: p.Attrs, Unset: p.Unset, Text: p.Text,
+		})
+	}
+	is.tree = tree
+
+	for _, call := range is.c.TakeCalls() {
+		send(call.Name, call.Value)
+	}
+	return true
+}
+
+// mountIsland returns the island rendered in root, mounting it from its
+// props on first use. The server-rendered HTML is taken to match the
+// island's first render. A wrapper the server replaced is mounted anew.
+func mountIsland(root js.Value) *island {
+	data := root.Get("dataset")
+	id := data.Get("forgeId").String()
+	name := data.Get("forgeIsland").String()
+	props := data.Get("forgeProps").String()
+	if is := mounted[id]; is != nil && is.el.Equal(root) && is.name == name && is.props == props {
+		return is
+	}
+	if !ui.HasIsland(name) {
+		return nil
+	}
+
+	var p map[string]string
+	json.Unmarshal([]byte(props), &p)
+	c := ui.NewIslandContext(p)
+	is := &island{el: root, name: name, props: props, c: c, tree: ui.RenderIsland(name, c)}
+	mounted[id] = is
+	return is
+}
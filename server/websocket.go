@@ -1,10 +1,17 @@
 package server
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/Shravanthh/forge/ctx"
 	"github.com/Shravanthh/forge/diff"
@@ -25,8 +32,34 @@ type Session struct {
 	LastUI  ui.UI
 	Page    PageFunc
 	mu      sync.Mutex
+	uploads map[string]*socketUpload
+	out     *outbox
+	guards  []Guard
 }
 
+// socketUpload is a file upload in progress on a session.
+type socketUpload struct {
+	handlerID  string
+	file       ctx.UploadedFile
+	buf        bytes.Buffer
+	progress   int
+	lastChunk  time.Time
+	lastRender time.Time // Of the last progress re-render
+}
+
+const (
+	maxSocketUploads       = 4                      // Uploads in progress per session
+	socketUploadIdle       = time.Minute            // Drops uploads that stop sending chunks
+	uploadProgressInterval = 100 * time.Millisecond // Between progress re-renders
+	sniffLen               = 512                    // Bytes http.DetectContentType considers
+	uploadChunkSize        = 64 << 10               // Bytes per upload_chunk sent by the clients
+)
+
+// maxSocketMessage is the read limit of a session socket: one upload chunk
+// in base64 plus room for the JSON envelope. It also bounds the value an
+// input event can carry; the connection is closed past it.
+var maxSocketMessage = int64(base64.StdEncoding.EncodedLen(uploadChunkSize) + 4<<10)
+
 // Message from client.
 type Message struct {
 	Type      string `json:"type"`
@@ -34,23 +67,36 @@ type Message struct {
 	Value     string `json:"value"`
 	ScrollTop int    `json:"scrollTop"`
 	DragID    string `json:"dragId"`
+	Upload    string `json:"upload"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	MIME      string `json:"mime"`
+	Data      []byte `json:"data"`
 }
 
 // Response to client.
 type Response struct {
-	Type    string       `json:"type"`
-	Patches []diff.Patch `json:"patches,omitempty"`
+	Type     string       `json:"type"`
+	Patches  []diff.Patch `json:"patches,omitempty"`
+	URL      string       `json:"url,omitempty"`
+	Filename string       `json:"filename,omitempty"`
 }
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin:       func(r *http.Request) bool { return true },
+	Subprotocols:      []string{ProtocolBinary, ProtocolJSON},
+	EnableCompression: true,
 }
 
 // SessionManager manages active sessions.
 type SessionManager struct {
-	mu       sync.RWMutex
-	sessions map[string]*Session
-	store    ctx.SessionStore
+	mu           sync.RWMutex
+	sessions     map[string]*Session
+	store        ctx.SessionStore
+	downloads    *downloadRegistry
+	newContext   func() *ctx.Context // Creates session Contexts
+	base         string              // Base path of download URLs
+	uploadPolicy UploadPolicy        // Limits of socket uploads
 }
 
 // NewSessionManager creates a session manager.
@@ -58,53 +104,59 @@ func NewSessionManager(store ctx.SessionStore) *SessionManager {
 	if store == nil {
 		store = ctx.NewMemoryStore()
 	}
-	return &SessionManager{sessions: make(map[string]*Session), store: store}
+	return &SessionManager{
+		sessions:     make(map[string]*Session),
+		store:        store,
+		downloads:    newDownloadRegistry(),
+		newContext:   ctx.New,
+		uploadPolicy: DefaultUploadPolicy,
+	}
 }
 
-var sessionCounter uint64
-
+// generateSessionID returns an unguessable session ID. Knowing an ID is
+// enough to restore the session's state.
 func generateSessionID() string {
-	return "s" + uitoa(atomic.AddUint64(&sessionCounter, 1))
-}
-
-func uitoa(i uint64) string {
-	if i == 0 {
-		return "0"
-	}
-	var b [20]byte
-	n := len(b)
-	for i > 0 {
-		n--
-		b[n] = byte('0' + i%10)
-		i /= 10
-	}
-	return string(b[n:])
+	var b [16]byte
+	rand.Read(b[:])
+	return "s" + hex.EncodeToString(b[:])
 }
 
 // HandleWebSocket handles WebSocket connections.
 func (sm *SessionManager) HandleWebSocket(page PageFunc, params map[string]string) http.HandlerFunc {
+	return sm.handleWebSocket(page, params, nil)
+}
+
+// handleWebSocket is HandleWebSocket for a page behind guards, which are
+// checked when the session connects and before every re-render.
+func (sm *SessionManager) handleWebSocket(page PageFunc, params map[string]string, guards []Guard) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("upgrade error: %v", err)
 			return
 		}
-
-		registerDevClient(conn)
-		defer unregisterDevClient(conn)
+		conn.SetReadLimit(maxSocketMessage)
 
 		sessionID := r.URL.Query().Get("session")
 		if sessionID == "" {
 			sessionID = generateSessionID()
 		}
 
-		c := ctx.New()
+		c := sm.newContext()
 		c.Params = params
+		c.SetQuery(pageQuery(r))
 		if state, _ := sm.store.Load(sessionID); state != nil {
 			c.RestoreState(state)
 		}
+		c.SetRoles(requestRoles(r)...)
+
+		if err := checkGuards(c, guards); err != nil {
+			conn.WriteJSON(refusal(err))
+			conn.Close()
+			return
+		}
 
-		ui.ResetEventCounter()
+		ui.ResetEventCounter(c)
 		initialUI := page(c)
 
 		session := &Session{
@@ -113,7 +165,13 @@ func (sm *SessionManager) HandleWebSocket(page PageFunc, params map[string]strin
 			Context: c,
 			LastUI:  initialUI,
 			Page:    page,
+			uploads: make(map[string]*socketUpload),
+			guards:  guards,
 		}
+		session.out = newOutbox(conn)
+
+		registerDevClient(session.out)
+		defer unregisterDevClient(session.out)
 
 		sm.mu.Lock()
 		sm.sessions[sessionID] = session
@@ -121,13 +179,18 @@ func (sm *SessionManager) HandleWebSocket(page PageFunc, params map[string]strin
 
 		defer func() {
 			sm.store.Save(sessionID, c.PersistentState())
+			session.out.close()
 			conn.Close()
+			session.mu.Lock()
+			session.uploads = nil // Release partial uploads
+			session.mu.Unlock()
 			sm.mu.Lock()
 			delete(sm.sessions, sessionID)
 			sm.mu.Unlock()
 		}()
 
-		conn.WriteJSON(map[string]string{"type": "session", "id": sessionID})
+		session.out.send(map[string]string{"type": "session", "id": sessionID})
+		sm.sendURL(session)
 
 		for {
 			var msg Message
@@ -143,6 +206,8 @@ func (sm *SessionManager) HandleWebSocket(page PageFunc, params map[string]strin
 			case "drop":
 				session.Context.Set("_drag_id", msg.DragID)
 				sm.handleEvent(session, msg)
+			case "upload_start", "upload_chunk", "upload_end":
+				sm.handleUpload(session, msg)
 			}
 		}
 	}
@@ -151,6 +216,9 @@ func (sm *SessionManager) HandleWebSocket(page PageFunc, params map[string]strin
 func (sm *SessionManager) handleEvent(s *Session, msg Message) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if refused(s) {
+		return
+	}
 
 	func() {
 		defer func() {
@@ -158,26 +226,211 @@ func (sm *SessionManager) handleEvent(s *Session, msg Message) {
 				log.Printf("handler panic: %v", r)
 			}
 		}()
+		var handled bool
 		if msg.Value != "" {
-			s.Context.HandleWithValue(msg.ID, msg.Value)
+			handled = s.Context.HandleWithValue(msg.ID, msg.Value)
 		} else {
-			s.Context.Handle(msg.ID)
+			handled = s.Context.Handle(msg.ID)
+		}
+		if !handled && isIslandEvent(msg.ID) {
+			warnInertIslands.Do(func() {
+				log.Printf("forge: event %q of a client island reached the server; islands need a WASM bundle built with wasmclient.EnableIslands (see docs/islands.md)", msg.ID)
+			})
+		}
+		// Handlers may call other handlers with c.Call, a bounded
+		// number of times so handlers calling each other cannot hang
+		// the session.
+		for round := 0; ; round++ {
+			calls := s.Context.TakeCalls()
+			if len(calls) == 0 {
+				break
+			}
+			if round == maxCallRounds {
+				log.Printf("forge: event %q: c.Call chain stopped after %d rounds, dropping %d calls", msg.ID, maxCallRounds, len(calls))
+				break
+			}
+			for _, call := range calls {
+				s.Context.HandleWithValue(call.Name, call.Value)
+			}
 		}
 	}()
 
-	ui.ResetEventCounter()
+	sm.rerender(s)
+}
+
+// maxCallRounds limits how many rounds of c.Call one event may cause.
+const maxCallRounds = 32
+
+// warnInertIslands logs the first island event the server receives.
+var warnInertIslands sync.Once
+
+// isIslandEvent reports whether id was generated inside a client island,
+// whose handlers live only in the WASM client.
+func isIslandEvent(id string) bool {
+	return strings.HasPrefix(id, "island-") && strings.Contains(id, ":")
+}
+
+// handleUpload assembles a file streamed in chunks and runs its handler
+// inside the session once complete. Files are held to the session
+// manager's UploadPolicy, with the type sniffed from the content rather
+// than taken from the browser. Progress is stored in the Context under
+// the handler ID so the page can render it.
+func (sm *SessionManager) handleUpload(s *Session, msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if refused(s) {
+		return
+	}
+	now := time.Now()
+	for id, up := range s.uploads {
+		if now.Sub(up.lastChunk) > socketUploadIdle {
+			delete(s.uploads, id)
+			log.Printf("upload %s abandoned", up.file.Name)
+		}
+	}
+	p := sm.uploadPolicy
+
+	switch msg.Type {
+	case "upload_start":
+		name := SanitizeFilename(msg.Name)
+		switch {
+		case !s.Context.HasUploadHandler(msg.ID):
+			log.Printf("upload %s rejected: no handler %q", name, msg.ID)
+			return
+		case len(s.uploads) >= maxSocketUploads:
+			log.Printf("upload %s rejected: %d uploads already in progress", name, len(s.uploads))
+			return
+		case msg.Size > p.MaxSize:
+			log.Printf("upload %s rejected: %d bytes exceeds limit", name, msg.Size)
+			return
+		}
+		s.uploads[msg.Upload] = &socketUpload{
+			handlerID: msg.ID,
+			file:      ctx.UploadedFile{Name: name, Size: msg.Size},
+			lastChunk: now,
+		}
+		s.Context.Set(msg.ID+"_progress", 0)
+		sm.rerender(s)
+
+	case "upload_chunk":
+		up, ok := s.uploads[msg.Upload]
+		if !ok {
+			return
+		}
+		if int64(up.buf.Len()+len(msg.Data)) > p.MaxSize {
+			delete(s.uploads, msg.Upload)
+			log.Printf("upload %s aborted: exceeds limit", up.file.Name)
+			return
+		}
+		up.buf.Write(msg.Data)
+		up.lastChunk = now
+		if up.buf.Len() >= sniffLen && !sm.sniff(s, msg.Upload, up) {
+			return
+		}
+		if up.file.Size > 0 && now.Sub(up.lastRender) >= uploadProgressInterval {
+			if pct := int(int64(up.buf.Len()) * 100 / up.file.Size); pct != up.progress {
+				up.progress = pct
+				up.lastRender = now
+				s.Context.Set(up.handlerID+"_progress", pct)
+				sm.rerender(s)
+			}
+		}
+
+	case "upload_end":
+		up, ok := s.uploads[msg.Upload]
+		if !ok || !sm.sniff(s, msg.Upload, up) {
+			return
+		}
+		delete(s.uploads, msg.Upload)
+		up.file.Data = up.buf.Bytes()
+		up.file.Size = int64(len(up.file.Data))
+		s.Context.Set(up.handlerID+"_progress", 100)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("upload handler panic: %v", r)
+				}
+			}()
+			s.Context.HandleUpload(up.handlerID, up.file)
+		}()
+		sm.rerender(s)
+	}
+}
+
+// sniff sets the content type of an upload from its first bytes, once,
+// and drops the upload if the policy does not allow the type.
+func (sm *SessionManager) sniff(s *Session, id string, up *socketUpload) bool {
+	if up.file.ContentType != "" {
+		return true
+	}
+	up.file.ContentType = http.DetectContentType(up.buf.Bytes())
+	if !typeAllowed(up.file.ContentType, sm.uploadPolicy.AllowedTypes) {
+		delete(s.uploads, id)
+		log.Printf("upload %s rejected: type %s not allowed", up.file.Name, up.file.ContentType)
+		return false
+	}
+	return true
+}
+
+// rerender renders the session page and queues the resulting patches,
+// followed by any downloads requested by handlers. The caller must hold s.mu.
+func (sm *SessionManager) rerender(s *Session) {
+	if refused(s) {
+		return
+	}
+	ui.ResetEventCounter(s.Context)
 	newUI := s.Page(s.Context)
 	patches := diff.Diff(s.LastUI, newUI)
 	s.LastUI = newUI
 
 	if len(patches) > 0 {
-		s.Conn.WriteJSON(Response{Type: "patch", Patches: patches})
+		s.out.queue(patches)
+	}
+	sm.sendURL(s)
+	sm.sendDownloads(s)
+}
+
+// sendURL updates the browser URL when keys synced with SyncURL changed.
+func (sm *SessionManager) sendURL(s *Session) {
+	if query, changed := s.Context.TakeURL(); changed {
+		s.out.send(Response{Type: "url", URL: query})
+	}
+}
+
+// pageQuery returns the query string of the page a WebSocket connects
+// for, which the client sends as the query parameter.
+func pageQuery(r *http.Request) url.Values {
+	q, _ := url.ParseQuery(strings.TrimPrefix(r.URL.Query().Get("query"), "?"))
+	return q
+}
+
+// refused checks the session's guards, which may have changed their
+// verdict since the page was rendered, and sends the refusal if one
+// fails. Nothing else may run for a refused session. The caller must
+// hold s.mu.
+func refused(s *Session) bool {
+	err := checkGuards(s.Context, s.guards)
+	if err == nil {
+		return false
+	}
+	s.out.send(refusal(err))
+	return true
+}
+
+// refusal is the message that tells a client its page was refused by a
+// guard: a redirect, or a reload so the HTTP render reports the error.
+func refusal(err error) Response {
+	var redirect *RedirectError
+	if errors.As(err, &redirect) {
+		return Response{Type: "redirect", URL: redirect.URL}
 	}
+	return Response{Type: "reload"}
 }
 
 // RenderInitialHTML renders the initial page HTML.
 func RenderInitialHTML(page PageFunc) string {
 	c := ctx.New()
-	ui.ResetEventCounter()
+	ui.ResetEventCounter(c)
 	return render.HTML(page(c))
 }
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketReadLimit(t *testing.T) {
+	app := New()
+	app.Route("/", page)
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	dial := func(t *testing.T) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?path=/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	chunk := func(n int) []byte {
+		msg, _ := json.Marshal(Message{Type: "upload_chunk", Upload: "u1", Data: bytes.Repeat([]byte{'x'}, n)})
+		return msg
+	}
+	// readUntil reads messages until the socket fails or stays quiet.
+	readUntil := func(conn *websocket.Conn) error {
+		for {
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return err
+			}
+		}
+	}
+
+	t.Run("full chunk", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+		if err := conn.WriteMessage(websocket.TextMessage, chunk(uploadChunkSize)); err != nil {
+			t.Fatal(err)
+		}
+		var ne net.Error
+		if err := readUntil(conn); !errors.As(err, &ne) || !ne.Timeout() {
+			t.Errorf("read after a full chunk = %v; want the socket to stay open", err)
+		}
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+		if err := conn.WriteMessage(websocket.TextMessage, chunk(int(maxSocketMessage))); err != nil {
+			t.Fatal(err)
+		}
+		if err := readUntil(conn); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+			t.Errorf("read after an oversized message = %v; want close %d", err, websocket.CloseMessageTooBig)
+		}
+	})
+}
@@ -1,9 +1,15 @@
 package ui
 
-// BreadcrumbItem represents a breadcrumb link.
+import "github.com/Shravanthh/forge/ctx"
+
+// BreadcrumbItem represents a breadcrumb link. With BreadcrumbsFor, Route
+// names the route to link to instead of Href, and Params holds its
+// parameters as key-value pairs.
 type BreadcrumbItem struct {
-	Label string
-	Href  string
+	Label  string
+	Href   string
+	Route  string
+	Params []string
 }
 
 // Breadcrumbs creates a breadcrumb navigation.
@@ -26,6 +32,25 @@ func Breadcrumbs(items []BreadcrumbItem) Element {
 	return Nav(children...).WithClass("breadcrumbs")
 }
 
+// BreadcrumbsFor is Breadcrumbs with the links of items that name a Route
+// built by c.URL.
+//
+//	ui.BreadcrumbsFor(c, []ui.BreadcrumbItem{
+//	    {Label: "Home", Route: "home"},
+//	    {Label: team.Name, Route: "team", Params: []string{"id", team.ID}},
+//	    {Label: "Members"},
+//	})
+func BreadcrumbsFor(c *ctx.Context, items []BreadcrumbItem) Element {
+	resolved := make([]BreadcrumbItem, len(items))
+	for i, item := range items {
+		if item.Route != "" {
+			item.Href = c.URL(item.Route, item.Params...)
+		}
+		resolved[i] = item
+	}
+	return Breadcrumbs(resolved)
+}
+
 // BreadcrumbStyles contains CSS for breadcrumbs.
 const BreadcrumbStyles = `
 .breadcrumbs{display:flex;align-items:center;gap:8px;font-size:14px}
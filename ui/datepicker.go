@@ -26,7 +26,7 @@ func DatePicker(id string, c *ctx.Context) Element {
 		WithAttr("type", "text").
 		WithAttr("value", selected).
 		WithAttr("placeholder", "YYYY-MM-DD").
-		WithAttr("readonly", "true").
+		ReadOnly(true).
 		WithClass("datepicker-input").
 		OnClick(c, func(c *ctx.Context) {
 			c.Set(id+"_open", !c.Bool(id+"_open"))
@@ -10,6 +10,16 @@
 //	ui.Input()              // <input />
 //	ui.T("text")            // text node
 //
+// # Control Flow
+//
+// Fragments group nodes without a wrapper element:
+//
+//	ui.Fragment(children...)               // Siblings in place
+//	ui.If(cond, node)                      // node or nothing
+//	ui.IfElse(cond, a, b)                  // a or b
+//	ui.Map(items, func(i, item) ui.UI)     // One node per item
+//	ui.Switch(value, cases, fallback)      // Matching case
+//
 // # Attributes
 //
 // Chain methods to add attributes:
@@ -143,6 +143,35 @@ func (e Element) WithAttr(k, v string) Element {
 	return e
 }
 
+// WithBool sets or removes a boolean attribute such as "disabled".
+//
+//	ui.Button(ui.T("Save")).WithBool("disabled", !form.Valid())
+func (e Element) WithBool(k string, on bool) Element {
+	if on {
+		return e.WithAttr(k, "")
+	}
+	delete(e.Attrs, k)
+	return e
+}
+
+// Disabled sets or removes the disabled attribute.
+func (e Element) Disabled(on bool) Element { return e.WithBool("disabled", on) }
+
+// Checked sets or removes the checked attribute.
+func (e Element) Checked(on bool) Element { return e.WithBool("checked", on) }
+
+// ReadOnly sets or removes the readonly attribute.
+func (e Element) ReadOnly(on bool) Element { return e.WithBool("readonly", on) }
+
+// Selected sets or removes the selected attribute.
+func (e Element) Selected(on bool) Element { return e.WithBool("selected", on) }
+
+// WithNS sets the element's namespace. Use it for MathML or for SVG
+// elements without a constructor in ui/svg:
+//
+//	ui.El("math", ui.El("mi", ui.T("x")).WithNS(ui.NamespaceMathML)).WithNS(ui.NamespaceMathML)
+func (e Element) WithNS(ns string) Element { e.NS = ns; return e }
+
 // WithChildren appends children to the element.
 func (e Element) WithChildren(children ...UI) Element {
 	e.Children = append(e.Children, children...)
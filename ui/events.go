@@ -2,24 +2,42 @@ package ui
 
 import (
 	"fmt"
-	"sync/atomic"
 
 	"github.com/Shravanthh/forge/ctx"
 )
 
-var eventCounter uint64
+// ResetEventCounter numbers the event IDs generated for c from 1 again
+// (call at start of each render). The count lives on c, so sessions
+// rendering at once do not share it.
+func ResetEventCounter(c *ctx.Context) {
+	c.ResetEventSeq()
+}
+
+// nextEventID generates an event ID, prefixed with c's event scope inside
+// a Memo or client island.
+func nextEventID(c *ctx.Context) string {
+	return fmt.Sprintf("%se%d", c.EventScope(), c.NextEventSeq())
+}
 
-// ResetEventCounter resets counter (call at start of each render)
-func ResetEventCounter() { atomic.StoreUint64(&eventCounter, 0) }
+// withEventScope runs fn with generated event IDs numbered from 1 under
+// scope, so they are the same wherever fn's output ends up in the page.
+// The scope and its count belong to c, so sessions rendering at once do
+// not share them.
+func withEventScope(c *ctx.Context, scope string, fn func() UI) UI {
+	return inEventScope(c, c.EventScope()+scope+":", fn)
+}
 
-func nextEventID() string {
-	return fmt.Sprintf("e%d", atomic.AddUint64(&eventCounter, 1))
+// inEventScope is withEventScope with an absolute prefix, ignoring any
+// enclosing scope.
+func inEventScope(c *ctx.Context, prefix string, fn func() UI) UI {
+	defer c.SetEventScope(prefix)()
+	return fn()
 }
 
 func (e Element) withEvent(c *ctx.Context, evtType string, handler ctx.EventHandler) Element {
 	id := e.ID
 	if id == "" {
-		id = nextEventID()
+		id = nextEventID(c)
 	}
 	handlerID := id + "_" + evtType
 	c.On(handlerID, handler)
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// eventPage generates event IDs before, inside and after a Memo.
+func eventPage(c *ctx.Context, n int) []string {
+	ResetEventCounter(c)
+	var ids []string
+	add := func(e Element) Element {
+		ids = append(ids, e.Events["click"])
+		return e
+	}
+	add(Button().OnClick(c, func(*ctx.Context) {}))
+	Memo("m", c, []any{n}, func() UI {
+		return add(Button().OnClick(c, func(*ctx.Context) {}))
+	})
+	add(Button().OnClick(c, func(*ctx.Context) {}))
+	return ids
+}
+
+func TestEventIDsPerContext(t *testing.T) {
+	want := []string{"e1_click", "m:e1_click", "e2_click"}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := ctx.New()
+			for n := range 100 {
+				if ids := eventPage(c, n); !slices.Equal(ids, want) {
+					t.Errorf("event IDs = %v; want %v", ids, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,71 @@
+package ui
+
+// Fragment groups nodes without a wrapper element. Its children render in
+// place of the fragment, as siblings of the fragment's own siblings.
+// Use Fragment() (no children) to render nothing.
+//
+//	ui.Ul(
+//	    ui.Li(ui.T("Home")),
+//	    ui.Fragment(ui.Li(ui.T("Docs")), ui.Li(ui.T("Blog"))),
+//	)
+type FragmentNode struct {
+	Children []UI // Nodes rendered in place of the fragment
+}
+
+func (FragmentNode) isUI() {}
+
+// Fragment creates a fragment from children.
+func Fragment(children ...UI) FragmentNode {
+	return FragmentNode{Children: children}
+}
+
+// If renders node when cond is true and nothing otherwise.
+// The slot is kept either way, so siblings after it keep their paths.
+//
+//	ui.If(c.Bool("error"), ui.Alert("Failed", "error"))
+func If(cond bool, node UI) UI {
+	if cond {
+		return node
+	}
+	return FragmentNode{}
+}
+
+// IfElse renders a when cond is true and b otherwise.
+func IfElse(cond bool, a, b UI) UI {
+	if cond {
+		return a
+	}
+	return b
+}
+
+// Map renders fn for each item as a fragment.
+// Give the returned elements IDs to keep them stable when items move.
+//
+//	ui.Ul(ui.Map(todos, func(i int, t Todo) ui.UI {
+//	    return ui.Li(ui.T(t.Title)).WithID("todo-" + t.ID)
+//	}))
+func Map[T any](items []T, fn func(int, T) UI) FragmentNode {
+	children := make([]UI, len(items))
+	for i, item := range items {
+		children[i] = fn(i, item)
+	}
+	return FragmentNode{Children: children}
+}
+
+// Switch renders the case matching value, or fallback if none does.
+// Only the chosen function is called, so the other views are neither
+// built nor register their handlers. A nil fallback renders nothing.
+//
+//	ui.Switch(c.String("view"), map[string]func() ui.UI{
+//	    "list": func() ui.UI { return ListView(c) },
+//	    "grid": func() ui.UI { return GridView(c) },
+//	}, func() ui.UI { return ListView(c) })
+func Switch[K comparable](value K, cases map[K]func() UI, fallback func() UI) UI {
+	if fn, ok := cases[value]; ok && fn != nil {
+		return fn()
+	}
+	if fallback == nil {
+		return FragmentNode{}
+	}
+	return fallback()
+}
@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"sync"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// IslandFunc renders a client island from its own Context. It reads its
+// props with c.String and keeps any further state in c, exactly like a
+// page function.
+type IslandFunc func(c *ctx.Context) UI
+
+var (
+	islandsMu sync.RWMutex
+	islands   = make(map[string]IslandFunc)
+)
+
+// RegisterIsland makes fn available as the client island name. Register
+// islands in an init function of a package imported by both the server
+// and the WASM bundle, so both sides render them the same way:
+//
+//	package islands
+//
+//	func init() { ui.RegisterIsland("counter", Counter) }
+//
+//	func Counter(c *ctx.Context) ui.UI {
+//	    return ui.Button(ui.T(fmt.Sprintf("Clicked %d", c.Int("n")))).
+//	        OnClick(c, func(c *ctx.Context) { c.Set("n", c.Int("n")+1) })
+//	}
+func RegisterIsland(name string, fn IslandFunc) {
+	islandsMu.Lock()
+	islands[name] = fn
+	islandsMu.Unlock()
+}
+
+// IslandNode is a component that runs in the browser. The server renders
+// it once for the initial HTML; after that the client runs its event
+// handlers against the island's own Context and patches it locally.
+// Server re-renders leave it alone unless Name or Props change, which
+// mounts it afresh.
+type IslandNode struct {
+	ID    string            // Optional key, as for Element.ID
+	Name  string            // Name passed to RegisterIsland
+	Props map[string]string // Initial state, set in the island's Context
+	Node  UI                // Tree rendered from Props
+}
+
+func (IslandNode) isUI() {}
+
+// Island renders the registered island name with props as its initial
+// state. Its handlers never reach the server; use c.Call inside the
+// island to invoke a server handler explicitly.
+//
+//	ui.Island("counter", map[string]string{"label": "Likes"})
+//
+// Islands need the WASM client built with the islands registered; see
+// docs/islands.md.
+func Island(name string, props map[string]string) IslandNode {
+	return IslandNode{Name: name, Props: props, Node: RenderIsland(name, NewIslandContext(props))}
+}
+
+// WithID sets the island's key, so it keeps its state when it moves
+// among its siblings.
+func (n IslandNode) WithID(id string) IslandNode { n.ID = id; return n }
+
+// NewIslandContext returns a Context holding props as string state.
+func NewIslandContext(props map[string]string) *ctx.Context {
+	c := ctx.New()
+	for k, v := range props {
+		c.Set(k, v)
+	}
+	return c
+}
+
+// RenderIsland renders the island name from c. Handler IDs are scoped to
+// the island, so the server and the client generate the same ones.
+// An unregistered name renders nothing.
+func RenderIsland(name string, c *ctx.Context) UI {
+	islandsMu.RLock()
+	fn := islands[name]
+	islandsMu.RUnlock()
+	if fn == nil {
+		return FragmentNode{}
+	}
+	return inEventScope(c, "island-"+name+":", func() UI { return fn(c) })
+}
+
+// HasIsland reports whether name is registered.
+func HasIsland(name string) bool {
+	islandsMu.RLock()
+	defer islandsMu.RUnlock()
+	return islands[name] != nil
+}
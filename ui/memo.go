@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"reflect"
+
+	"github.com/Shravanthh/forge/ctx"
+)
+
+// Memoized is a subtree produced by Memo. Two Memoized nodes with the
+// same Key and Gen hold the same subtree, so diffing skips them.
+type Memoized struct {
+	Key  string // Memo key
+	Gen  uint64 // Incremented each time the subtree is re-rendered
+	Node UI     // The memoized subtree
+}
+
+func (Memoized) isUI() {}
+
+type memoEntry struct {
+	deps []any
+	node UI
+	gen  uint64
+}
+
+// Memo renders a subtree only when its dependencies change.
+// While deps are equal (reflect.DeepEqual) to those of the previous render
+// of the same key in this session, the previous subtree and the handlers it
+// registered are reused, and diffing skips it entirely.
+//
+// c is the session's Context: the previous deps and subtree are kept in
+// its state, so each session memoizes on its own, and the handlers fn
+// registers stay on it. Handlers inside fn get IDs scoped to the key, so
+// they cannot collide with handlers outside the memo. fn must only depend
+// on deps.
+//
+// A memo may return a fragment; its children are skipped by diffing
+// like a single node.
+//
+//	ui.Memo("orders", c, []any{orders, sortKey}, func() ui.UI {
+//	    return OrdersTable(c, orders, sortKey)
+//	})
+func Memo(key string, c *ctx.Context, deps []any, fn func() UI) UI {
+	stateKey := "_memo_" + key
+	prev, _ := c.Get(stateKey).(*memoEntry)
+	if prev != nil && reflect.DeepEqual(prev.deps, deps) {
+		return Memoized{Key: key, Gen: prev.gen, Node: prev.node}
+	}
+
+	entry := &memoEntry{deps: deps, node: withEventScope(c, key, fn), gen: 1}
+	if prev != nil {
+		entry.gen = prev.gen + 1
+	}
+	c.Set(stateKey, entry)
+	return Memoized{Key: key, Gen: entry.gen, Node: entry.node}
+}
+
+// Unwrap returns the subtree a Memoized node holds, or n itself.
+func Unwrap(n UI) UI {
+	for {
+		m, ok := n.(Memoized)
+		if !ok {
+			return n
+		}
+		n = m.Node
+	}
+}
@@ -25,7 +25,7 @@ func Pagination(id string, c *ctx.Context, total, perPage int) Element {
 			c.Set(id+"_page", c.Int(id+"_page")-1)
 		})
 	} else {
-		prevBtn = prevBtn.WithAttr("disabled", "true").WithClass("page-btn disabled")
+		prevBtn = prevBtn.Disabled(true).WithClass("page-btn disabled")
 	}
 	buttons = append(buttons, prevBtn)
 
@@ -64,7 +64,7 @@ func Pagination(id string, c *ctx.Context, total, perPage int) Element {
 			c.Set(id+"_page", c.Int(id+"_page")+1)
 		})
 	} else {
-		nextBtn = nextBtn.WithAttr("disabled", "true").WithClass("page-btn disabled")
+		nextBtn = nextBtn.Disabled(true).WithClass("page-btn disabled")
 	}
 	buttons = append(buttons, nextBtn)
 
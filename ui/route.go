@@ -0,0 +1,12 @@
+package ui
+
+import "github.com/Shravanthh/forge/ctx"
+
+// Route sets the href of a link to the route named name, with params as
+// key-value pairs (see ctx.Context.URL).
+//
+//	// app.Route("/user/:id", UserPage, forge.Name("user"))
+//	ui.A(ui.T("Profile")).Route(c, "user", "id", id)
+func (e Element) Route(c *ctx.Context, name string, params ...string) Element {
+	return e.WithAttr("href", c.URL(name, params...))
+}
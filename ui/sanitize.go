@@ -0,0 +1,423 @@
+package ui
+
+import (
+	"html"
+	"strings"
+)
+
+// Policy is an allowlist for SafeHTML. Elements not listed are dropped
+// but their text is kept; script-like elements are dropped with their
+// content. Comments, doctypes and processing instructions are removed.
+type Policy struct {
+	Elements   map[string][]string // Allowed tags and the attributes allowed on each
+	Attrs      []string            // Attributes allowed on every allowed tag
+	URLSchemes []string            // Schemes allowed in URL attributes; relative URLs are always allowed
+	LinkRel    string              // If set, forced as rel on every <a>
+}
+
+// StrictPolicy allows no markup: only text survives.
+func StrictPolicy() *Policy {
+	return &Policy{Elements: map[string][]string{}}
+}
+
+// BasicPolicy allows inline formatting, paragraphs, lists, quotes and code.
+func BasicPolicy() *Policy {
+	p := StrictPolicy()
+	for _, tag := range []string{
+		"b", "strong", "i", "em", "u", "s", "del", "ins", "mark", "small", "sub", "sup",
+		"p", "br", "hr", "blockquote", "code", "pre", "ul", "ol", "li",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+	} {
+		p.Elements[tag] = nil
+	}
+	return p
+}
+
+// UGCPolicy extends BasicPolicy for user-generated content with links,
+// images and tables. Links get rel="nofollow noopener noreferrer".
+func UGCPolicy() *Policy {
+	p := BasicPolicy()
+	p.Elements["a"] = []string{"href", "title"}
+	p.Elements["img"] = []string{"src", "alt", "title", "width", "height"}
+	for _, tag := range []string{"table", "thead", "tbody", "tr", "th", "td", "dl", "dt", "dd", "abbr", "cite", "q"} {
+		p.Elements[tag] = nil
+	}
+	p.Elements["blockquote"] = []string{"cite"}
+	p.Elements["th"] = []string{"colspan", "rowspan"}
+	p.Elements["td"] = []string{"colspan", "rowspan"}
+	p.URLSchemes = []string{"http", "https", "mailto"}
+	p.LinkRel = "nofollow noopener noreferrer"
+	return p
+}
+
+// Allow adds tag to the policy with the given attributes and returns p.
+//
+//	policy := ui.BasicPolicy().Allow("span", "title")
+func (p *Policy) Allow(tag string, attrs ...string) *Policy {
+	tag = strings.ToLower(tag)
+	if p.Elements == nil {
+		p.Elements = make(map[string][]string)
+	}
+	p.Elements[tag] = append(p.Elements[tag], attrs...)
+	return p
+}
+
+// SafeHTML sanitizes untrusted HTML with policy and returns it as Raw.
+// Use it instead of Raw for Markdown output, CMS content or anything
+// users can influence. A nil policy is StrictPolicy.
+//
+//	ui.SafeHTML(comment.Body, ui.UGCPolicy())
+func SafeHTML(s string, policy *Policy) Raw {
+	if policy == nil {
+		policy = StrictPolicy()
+	}
+	return Raw{HTML: Sanitize(s, policy)}
+}
+
+// Sanitize returns s with everything policy does not allow removed.
+// The result is well-formed: entities are normalized, attribute values
+// are quoted and every allowed element is closed.
+func Sanitize(s string, policy *Policy) string {
+	z := sanitizer{policy: policy, src: s}
+	z.run()
+	return z.out.String()
+}
+
+// dropContent lists elements removed together with everything inside.
+var dropContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "noscript": true, "textarea": true, "title": true,
+	"svg": true, "math": true, "select": true, "frameset": true, "noembed": true,
+}
+
+// rawText lists elements whose content is not parsed as markup.
+var rawText = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+	"noscript": true, "noembed": true, "iframe": true, "xmp": true,
+}
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// urlAttrs lists attributes holding URLs.
+var urlAttrs = map[string]bool{
+	"href": true, "src": true, "cite": true, "action": true, "formaction": true,
+	"poster": true, "background": true, "longdesc": true, "xlink:href": true,
+}
+
+type attr struct{ name, value string }
+
+// sanitizer is a small HTML tokenizer that writes only allowed tokens.
+type sanitizer struct {
+	policy *Policy
+	src    string
+	pos    int
+	out    strings.Builder
+	open   []string // Allowed elements currently open
+	skip   string   // Element whose content is being dropped
+	depth  int      // Nesting of skip
+}
+
+func (z *sanitizer) run() {
+	for z.pos < len(z.src) {
+		i := strings.IndexByte(z.src[z.pos:], '<')
+		if i < 0 {
+			z.text(z.src[z.pos:])
+			break
+		}
+		z.text(z.src[z.pos : z.pos+i])
+		z.pos += i
+		z.tag()
+	}
+	for i := len(z.open) - 1; i >= 0; i-- {
+		z.writeEnd(z.open[i])
+	}
+}
+
+func (z *sanitizer) text(s string) {
+	if z.skip == "" && s != "" {
+		z.out.WriteString(html.EscapeString(html.UnescapeString(s)))
+	}
+}
+
+// tag consumes the markup at z.pos, which starts with '<'.
+func (z *sanitizer) tag() {
+	rest := z.src[z.pos:]
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		z.skipPast(4, "-->")
+	case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+		z.skipPast(2, ">")
+	case strings.HasPrefix(rest, "</") && len(rest) > 2 && isASCIILetter(rest[2]):
+		z.pos += 2
+		name := z.name()
+		z.skipPast(0, ">")
+		z.end(name)
+	case len(rest) > 1 && isASCIILetter(rest[1]):
+		z.pos++
+		name := z.name()
+		attrs, selfClose := z.attrs()
+		z.start(name, attrs, selfClose)
+	default:
+		// A '<' that does not start a tag is text.
+		z.text("<")
+		z.pos++
+	}
+}
+
+func (z *sanitizer) skipPast(offset int, end string) {
+	z.pos += offset
+	if i := strings.Index(z.src[z.pos:], end); i >= 0 {
+		z.pos += i + len(end)
+	} else {
+		z.pos = len(z.src)
+	}
+}
+
+func (z *sanitizer) name() string {
+	start := z.pos
+	for z.pos < len(z.src) && !isSpace(z.src[z.pos]) && z.src[z.pos] != '/' && z.src[z.pos] != '>' {
+		z.pos++
+	}
+	return strings.ToLower(z.src[start:z.pos])
+}
+
+// attrs parses attributes up to and including the closing '>'.
+func (z *sanitizer) attrs() (attrs []attr, selfClose bool) {
+	for z.pos < len(z.src) {
+		c := z.src[z.pos]
+		switch {
+		case c == '>':
+			z.pos++
+			return attrs, selfClose
+		case c == '/':
+			selfClose = true
+			z.pos++
+			continue
+		case isSpace(c):
+			z.pos++
+			continue
+		}
+		selfClose = false
+
+		start := z.pos
+		for z.pos < len(z.src) && !isSpace(z.src[z.pos]) && !strings.ContainsRune("/>=", rune(z.src[z.pos])) {
+			z.pos++
+		}
+		if z.pos == start {
+			// A stray '=' without a name.
+			z.pos++
+			continue
+		}
+		a := attr{name: strings.ToLower(z.src[start:z.pos])}
+		z.skipSpace()
+		if z.pos < len(z.src) && z.src[z.pos] == '=' {
+			z.pos++
+			z.skipSpace()
+			a.value = html.UnescapeString(z.value())
+		}
+		attrs = append(attrs, a)
+	}
+	return attrs, selfClose
+}
+
+func (z *sanitizer) value() string {
+	if z.pos >= len(z.src) {
+		return ""
+	}
+	if q := z.src[z.pos]; q == '"' || q == '\'' {
+		z.pos++
+		end := strings.IndexByte(z.src[z.pos:], q)
+		if end < 0 {
+			v := z.src[z.pos:]
+			z.pos = len(z.src)
+			return v
+		}
+		v := z.src[z.pos : z.pos+end]
+		z.pos += end + 1
+		return v
+	}
+	start := z.pos
+	for z.pos < len(z.src) && !isSpace(z.src[z.pos]) && z.src[z.pos] != '>' {
+		z.pos++
+	}
+	return z.src[start:z.pos]
+}
+
+func (z *sanitizer) skipSpace() {
+	for z.pos < len(z.src) && isSpace(z.src[z.pos]) {
+		z.pos++
+	}
+}
+
+func (z *sanitizer) start(name string, attrs []attr, selfClose bool) {
+	if z.skip != "" {
+		if name == z.skip && !selfClose {
+			z.depth++
+		}
+		return
+	}
+	if dropContent[name] {
+		if rawText[name] {
+			z.skipRawText(name)
+		} else if !selfClose && !voidElements[name] {
+			z.skip, z.depth = name, 1
+		}
+		return
+	}
+	allowed, ok := z.policy.Elements[name]
+	if !ok {
+		return
+	}
+
+	z.out.WriteByte('<')
+	z.out.WriteString(name)
+	for _, a := range attrs {
+		if a.name == "rel" && name == "a" && z.policy.LinkRel != "" {
+			continue
+		}
+		if !z.attrAllowed(a.name, allowed) {
+			continue
+		}
+		if urlAttrs[a.name] && !z.urlAllowed(a.value) {
+			continue
+		}
+		z.out.WriteByte(' ')
+		z.out.WriteString(a.name)
+		z.out.WriteString(`="`)
+		z.out.WriteString(html.EscapeString(a.value))
+		z.out.WriteByte('"')
+	}
+	if name == "a" && z.policy.LinkRel != "" {
+		z.out.WriteString(` rel="`)
+		z.out.WriteString(html.EscapeString(z.policy.LinkRel))
+		z.out.WriteByte('"')
+	}
+	if voidElements[name] {
+		z.out.WriteString(" />")
+		return
+	}
+	z.out.WriteByte('>')
+	if selfClose {
+		z.writeEnd(name)
+		return
+	}
+	z.open = append(z.open, name)
+}
+
+// skipRawText drops everything up to the matching end tag of a raw text element.
+func (z *sanitizer) skipRawText(name string) {
+	closing := "</" + name
+	lower := strings.ToLower(z.src[z.pos:])
+	if i := strings.Index(lower, closing); i >= 0 {
+		z.pos += i + len(closing)
+		z.skipPast(0, ">")
+	} else {
+		z.pos = len(z.src)
+	}
+}
+
+func (z *sanitizer) end(name string) {
+	if z.skip != "" {
+		if name == z.skip {
+			if z.depth--; z.depth == 0 {
+				z.skip = ""
+			}
+		}
+		return
+	}
+	// Close any allowed elements left open inside this one.
+	for i := len(z.open) - 1; i >= 0; i-- {
+		if z.open[i] == name {
+			for j := len(z.open) - 1; j >= i; j-- {
+				z.writeEnd(z.open[j])
+			}
+			z.open = z.open[:i]
+			return
+		}
+	}
+}
+
+func (z *sanitizer) writeEnd(name string) {
+	z.out.WriteString("</")
+	z.out.WriteString(name)
+	z.out.WriteByte('>')
+}
+
+func (z *sanitizer) attrAllowed(name string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == name {
+			return true
+		}
+	}
+	for _, a := range z.policy.Attrs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// urlAllowed reports whether a URL is relative or uses an allowed scheme.
+func (z *sanitizer) urlAllowed(u string) bool {
+	scheme, ok := urlScheme(u)
+	if !ok {
+		return true
+	}
+	for _, s := range z.policy.URLSchemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// SafeURL reports whether u is safe in an href or src written by code:
+// relative, or any scheme except javascript:, vbscript: and data: (data
+// is allowed for raster images, audio and video).
+func SafeURL(u string) bool {
+	scheme, ok := urlScheme(u)
+	if !ok {
+		return true
+	}
+	switch scheme {
+	case "javascript", "vbscript":
+		return false
+	case "data":
+		rest := strings.ToLower(strings.TrimLeft(u, "\x00\t\n\f\r "))
+		rest = strings.TrimPrefix(rest, "data:")
+		if strings.HasPrefix(rest, "image/svg") {
+			return false
+		}
+		return strings.HasPrefix(rest, "image/") || strings.HasPrefix(rest, "audio/") || strings.HasPrefix(rest, "video/")
+	}
+	return true
+}
+
+// urlScheme returns the scheme of u as a browser would see it, ignoring
+// the whitespace and control characters browsers strip ("java\tscript:").
+// ok is false for relative URLs.
+func urlScheme(u string) (scheme string, ok bool) {
+	var b strings.Builder
+	for i := 0; i < len(u); i++ {
+		c := u[i]
+		switch {
+		case c <= ' ' || c == 0x7f:
+			continue
+		case c == ':':
+			return strings.ToLower(b.String()), b.Len() > 0
+		case c == '/' || c == '?' || c == '#':
+			return "", false
+		}
+		b.WriteByte(c)
+	}
+	return "", false
+}
+
+func isASCIILetter(c byte) bool { return c|0x20 >= 'a' && c|0x20 <= 'z' }
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,158 @@
+package ui
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *Policy
+		in     string
+		want   string
+	}{
+		// javascript: URLs, plain and obfuscated.
+		{"javascript url", UGCPolicy(), `<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript upper case", UGCPolicy(), `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript leading space", UGCPolicy(), `<a href="  javascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript tab entity", UGCPolicy(), `<a href="jav&#x09;ascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript newline entity", UGCPolicy(), `<a href="jav&#10;ascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript raw tab", UGCPolicy(), "<a href=\"java\tscript:alert(1)\">x</a>", `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript nul", UGCPolicy(), "<a href=\"java\x00script:alert(1)\">x</a>", `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript decimal entities", UGCPolicy(), `<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript entity without semicolon", UGCPolicy(), `<a href="javascript&#58alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript named tab", UGCPolicy(), `<a href="java&Tab;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript colon entity", UGCPolicy(), `<a href="javascript&colon;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript unquoted", UGCPolicy(), `<a href=javascript:alert(1)>x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript img src", UGCPolicy(), `<img src="javascript:alert(1)">`, `<img />`},
+		{"vbscript url", UGCPolicy(), `<a href="vbscript:msgbox(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"data url", UGCPolicy(), `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"allowed scheme", UGCPolicy(), `<a href="https://example.com/?a=1&amp;b=2">x</a>`, `<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener noreferrer">x</a>`},
+		{"relative url", UGCPolicy(), `<a href="/docs:intro">x</a>`, `<a href="/docs:intro" rel="nofollow noopener noreferrer">x</a>`},
+		{"blockquote cite", UGCPolicy(), `<blockquote cite="javascript:alert(1)">q</blockquote>`, `<blockquote>q</blockquote>`},
+
+		// SVG and MathML are dropped with everything inside.
+		{"svg script", UGCPolicy(), `<svg><script>alert(1)</script></svg>ok`, `ok`},
+		{"svg onload", UGCPolicy(), `<svg onload=alert(1)>x</svg>ok`, `ok`},
+		{"svg self-closing slash", UGCPolicy(), `<svg/onload=alert(1)>x</svg>ok`, `ok`},
+		{"svg nested", UGCPolicy(), `<svg><svg></svg><a href="javascript:alert(1)">x</a></svg>ok`, `ok`},
+		{"svg foreignObject", UGCPolicy(), `<svg><foreignObject><img src=x onerror=alert(1)></foreignObject></svg>ok`, `ok`},
+		{"math xlink", UGCPolicy(), `<math><mi xlink:href="javascript:alert(1)">x</mi></math>ok`, `ok`},
+		{"math mglyph style", UGCPolicy(), `<math><mtext><table><mglyph><style><img src=x onerror=alert(1)>`, ``},
+		{"svg upper case", UGCPolicy(), `<SVG><p>x</p></SVG>ok`, `ok`},
+
+		// Unclosed and malformed tags.
+		{"unclosed element", BasicPolicy(), `<b>bold`, `<b>bold</b>`},
+		{"unclosed nesting", BasicPolicy(), `<ul><li><b>x`, `<ul><li><b>x</b></li></ul>`},
+		{"misnested", BasicPolicy(), `<b><i>x</b>y</i>`, `<b><i>x</i></b>y`},
+		{"stray end tag", BasicPolicy(), `x</p></b>`, `x`},
+		{"unclosed tag at end", BasicPolicy(), `x<b`, `x<b></b>`},
+		{"unclosed attribute", UGCPolicy(), `<a href="/x>y`, `<a href="/x&gt;y" rel="nofollow noopener noreferrer"></a>`},
+		{"lone angle", BasicPolicy(), `1 < 2 <3`, `1 &lt; 2 &lt;3`},
+		{"split script", BasicPolicy(), `<scr<script>ipt>alert(1)</script>`, `ipt&gt;alert(1)`},
+		{"comment", BasicPolicy(), `a<!-- <img src=x onerror=alert(1)> -->b`, `ab`},
+		{"unclosed comment", BasicPolicy(), `a<!-- <b>x</b>`, `a`},
+		{"doctype", BasicPolicy(), `<!DOCTYPE html><p>x</p>`, `<p>x</p>`},
+		{"processing instruction", BasicPolicy(), `<?xml version="1.0"?>x`, `x`},
+		{"cdata", BasicPolicy(), `<![CDATA[<script>alert(1)</script>]]>x`, `alert(1)]]&gt;x`},
+
+		// Raw text elements drop their content, markup included.
+		{"script", BasicPolicy(), `a<script>document.write("<b>x</b>")</script>b`, `ab`},
+		{"script upper case", BasicPolicy(), `a<SCRIPT>alert(1)</ScRiPt >b`, `ab`},
+		{"unclosed script", BasicPolicy(), `a<script>alert(1)`, `a`},
+		{"style", BasicPolicy(), `<style>p{}</style><p>x</p>`, `<p>x</p>`},
+		{"textarea", UGCPolicy(), `<textarea><img src=x onerror=alert(1)></textarea>ok`, `ok`},
+		{"title", UGCPolicy(), `<title></title><img src=x onerror=alert(1)>`, `<img src="x" />`},
+		{"noscript attribute", UGCPolicy(), `<noscript><p title="</noscript><img src=x onerror=alert(1)>">`, `<img src="x" />&#34;&gt;`},
+		{"iframe", UGCPolicy(), `<iframe src="https://evil"></iframe>ok`, `ok`},
+
+		// Attribute names and values.
+		{"event handler", UGCPolicy(), `<img src="/a.png" onerror="alert(1)">`, `<img src="/a.png" />`},
+		{"event handler unquoted", UGCPolicy(), `<img src=/a.png onerror=alert(1)>`, `<img src="/a.png" />`},
+		{"handler after slash", UGCPolicy(), `<img/src="/a.png"/onerror=alert(1)>`, `<img src="/a.png" />`},
+		{"quoted name", UGCPolicy(), `<a title="x" "onmouseover=alert(1)">y</a>`, `<a title="x" rel="nofollow noopener noreferrer">y</a>`},
+		{"value breaks out", UGCPolicy(), `<a title='" onmouseover="alert(1)'>y</a>`, `<a title="&#34; onmouseover=&#34;alert(1)" rel="nofollow noopener noreferrer">y</a>`},
+		{"entity quote in value", UGCPolicy(), `<a title="&quot;><script>alert(1)</script>">y</a>`, `<a title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;" rel="nofollow noopener noreferrer">y</a>`},
+		{"stray equals", UGCPolicy(), `<a = title="t">y</a>`, `<a title="t" rel="nofollow noopener noreferrer">y</a>`},
+		{"attribute case", UGCPolicy(), `<a TITLE="t" OnClick="x">y</a>`, `<a title="t" rel="nofollow noopener noreferrer">y</a>`},
+		{"rel overridden", UGCPolicy(), `<a href="/x" rel="opener">y</a>`, `<a href="/x" rel="nofollow noopener noreferrer">y</a>`},
+		{"style attribute", UGCPolicy(), `<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+
+		// Text is normalized.
+		{"entities", StrictPolicy(), `&lt;b&gt; &amp; &#x3C;i&#62; &copy;`, `&lt;b&gt; &amp; &lt;i&gt; ©`},
+		{"quotes in text", StrictPolicy(), `"it's"`, `&#34;it&#39;s&#34;`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in, tt.policy); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizePolicies(t *testing.T) {
+	const in = `<h1 id="t">Title</h1><p class="c"><b>b</b> <span title="s">s</span></p>` +
+		`<a href="https://example.com" title="l">l</a><img src="/i.png" alt="i">` +
+		`<table><tr><td colspan="2" style="x">c</td></tr></table>`
+	tests := []struct {
+		name   string
+		policy *Policy
+		want   string
+	}{
+		{"strict", StrictPolicy(), `Titleb sl` + `c`},
+		{"nil", nil, `Titleb sl` + `c`},
+		{"basic", BasicPolicy(), `<h1>Title</h1><p><b>b</b> s</p>lc`},
+		{"ugc", UGCPolicy(), `<h1>Title</h1><p><b>b</b> s</p>` +
+			`<a href="https://example.com" title="l" rel="nofollow noopener noreferrer">l</a><img src="/i.png" alt="i" />` +
+			`<table><tr><td colspan="2">c</td></tr></table>`},
+		{"allow", BasicPolicy().Allow("SPAN", "title"), `<h1>Title</h1><p><b>b</b> <span title="s">s</span></p>lc`},
+		{"global attrs", &Policy{Elements: map[string][]string{"h1": nil, "p": nil}, Attrs: []string{"id", "class"}},
+			`<h1 id="t">Title</h1><p class="c">b s</p>lc`},
+		{"schemes", &Policy{Elements: map[string][]string{"a": {"href"}}, URLSchemes: []string{"HTTPS"}},
+			`Titleb s<a href="https://example.com">l</a>c`},
+		{"no schemes", &Policy{Elements: map[string][]string{"a": {"href"}}},
+			`Titleb s<a>l</a>c`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if tt.policy == nil {
+				got = string(SafeHTML(in, nil).HTML)
+			} else {
+				got = Sanitize(in, tt.policy)
+			}
+			if got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"/path", true},
+		{"page?x=javascript:1", true},
+		{"#javascript:x", true},
+		{"https://example.com", true},
+		{"mailto:a@example.com", true},
+		{"javascript:alert(1)", false},
+		{" JavaScript:alert(1)", false},
+		{"java\tscript:alert(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"\x00javascript:alert(1)", false},
+		{"vbscript:msgbox(1)", false},
+		{"data:image/png;base64,AAAA", true},
+		{"data:video/mp4;base64,AAAA", true},
+		{"data:image/svg+xml;base64,AAAA", false},
+		{"DATA:IMAGE/SVG+XML,<svg/onload=alert(1)>", false},
+		{"data:text/html,<script>alert(1)</script>", false},
+		{" data:text/html,x", false},
+	}
+	for _, tt := range tests {
+		if got := SafeURL(tt.url); got != tt.want {
+			t.Errorf("SafeURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
@@ -1,19 +1,25 @@
 package ui
 
-import "strings"
+import (
+	"maps"
+	"slices"
+	"strings"
+)
 
 // Style is a chainable CSS style builder.
 type Style map[string]string
 
+// String returns the declarations sorted by property name, so equal
+// styles always produce the same string.
 func (s Style) String() string {
 	var b strings.Builder
-	for k, v := range s {
+	for _, k := range slices.Sorted(maps.Keys(s)) {
 		if b.Len() > 0 {
 			b.WriteByte(';')
 		}
 		b.WriteString(k)
 		b.WriteByte(':')
-		b.WriteString(v)
+		b.WriteString(s[k])
 	}
 	return b.String()
 }
@@ -0,0 +1,161 @@
+// Package svg provides constructors for SVG elements.
+//
+// Elements are created in the SVG namespace, so they render, diff and
+// patch correctly when a chart or icon updates live:
+//
+//	svg.Svg("0 0 100 100",
+//	    svg.Circle(50, 50, 40).WithAttr("fill", "#3b82f6"),
+//	    svg.Text(50, 55, ui.T(label)).WithAttr("text-anchor", "middle"),
+//	).WithAttr("width", "100")
+//
+// Attributes are set with WithAttr as for HTML elements. Use
+// ui.El(tag).WithNS(ui.NamespaceSVG) for elements not listed here.
+package svg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Shravanthh/forge/ui"
+)
+
+func el(tag string, children ...ui.UI) ui.Element {
+	return ui.Element{Tag: tag, NS: ui.NamespaceSVG, Children: children}
+}
+
+func num(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+// Point is a coordinate for Polyline and Polygon.
+type Point struct{ X, Y float64 }
+
+func points(pts []Point) string {
+	var b strings.Builder
+	for i, p := range pts {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(num(p.X))
+		b.WriteByte(',')
+		b.WriteString(num(p.Y))
+	}
+	return b.String()
+}
+
+// Containers
+
+// Svg creates an <svg> root element with the given viewBox, e.g. "0 0 24 24".
+func Svg(viewBox string, children ...ui.UI) ui.Element {
+	e := el("svg", children...).WithAttr("xmlns", ui.NamespaceSVG)
+	if viewBox != "" {
+		e = e.WithAttr("viewBox", viewBox)
+	}
+	return e
+}
+
+// G creates a <g> group element.
+func G(children ...ui.UI) ui.Element { return el("g", children...) }
+
+// Defs creates a <defs> element for reusable definitions.
+func Defs(children ...ui.UI) ui.Element { return el("defs", children...) }
+
+// Symbol creates a <symbol> element with the given id.
+func Symbol(id string, children ...ui.UI) ui.Element {
+	return el("symbol", children...).WithAttr("id", id)
+}
+
+// Use creates a <use> element referencing href, e.g. "#icon-star".
+func Use(href string) ui.Element { return el("use").WithAttr("href", href) }
+
+// ClipPath creates a <clipPath> element with the given id.
+func ClipPath(id string, children ...ui.UI) ui.Element {
+	return el("clipPath", children...).WithAttr("id", id)
+}
+
+// Mask creates a <mask> element with the given id.
+func Mask(id string, children ...ui.UI) ui.Element {
+	return el("mask", children...).WithAttr("id", id)
+}
+
+// Shapes
+
+// Path creates a <path> element with path data d.
+func Path(d string) ui.Element { return el("path").WithAttr("d", d) }
+
+// Circle creates a <circle> element.
+func Circle(cx, cy, r float64) ui.Element {
+	return el("circle").WithAttr("cx", num(cx)).WithAttr("cy", num(cy)).WithAttr("r", num(r))
+}
+
+// Ellipse creates an <ellipse> element.
+func Ellipse(cx, cy, rx, ry float64) ui.Element {
+	return el("ellipse").WithAttr("cx", num(cx)).WithAttr("cy", num(cy)).
+		WithAttr("rx", num(rx)).WithAttr("ry", num(ry))
+}
+
+// Rect creates a <rect> element.
+func Rect(x, y, width, height float64) ui.Element {
+	return el("rect").WithAttr("x", num(x)).WithAttr("y", num(y)).
+		WithAttr("width", num(width)).WithAttr("height", num(height))
+}
+
+// Line creates a <line> element.
+func Line(x1, y1, x2, y2 float64) ui.Element {
+	return el("line").WithAttr("x1", num(x1)).WithAttr("y1", num(y1)).
+		WithAttr("x2", num(x2)).WithAttr("y2", num(y2))
+}
+
+// Polyline creates a <polyline> element through pts.
+func Polyline(pts ...Point) ui.Element { return el("polyline").WithAttr("points", points(pts)) }
+
+// Polygon creates a closed <polygon> element through pts.
+func Polygon(pts ...Point) ui.Element { return el("polygon").WithAttr("points", points(pts)) }
+
+// Text
+
+// Text creates a <text> element at x, y.
+func Text(x, y float64, children ...ui.UI) ui.Element {
+	return el("text", children...).WithAttr("x", num(x)).WithAttr("y", num(y))
+}
+
+// TSpan creates a <tspan> element inside Text.
+func TSpan(children ...ui.UI) ui.Element { return el("tspan", children...) }
+
+// Title creates a <title> element, shown as a tooltip and read by screen readers.
+func Title(text string) ui.Element { return el("title", ui.T(text)) }
+
+// Desc creates a <desc> description element.
+func Desc(text string) ui.Element { return el("desc", ui.T(text)) }
+
+// Paint servers
+
+// LinearGradient creates a <linearGradient> element with the given id.
+func LinearGradient(id string, stops ...ui.UI) ui.Element {
+	return el("linearGradient", stops...).WithAttr("id", id)
+}
+
+// RadialGradient creates a <radialGradient> element with the given id.
+func RadialGradient(id string, stops ...ui.UI) ui.Element {
+	return el("radialGradient", stops...).WithAttr("id", id)
+}
+
+// Stop creates a gradient <stop> at offset (0 to 1) with the given color.
+func Stop(offset float64, color string) ui.Element {
+	return el("stop").WithAttr("offset", num(offset)).WithAttr("stop-color", color)
+}
+
+// Other
+
+// Image creates an <image> element.
+func Image(href string, x, y, width, height float64) ui.Element {
+	e := Rect(x, y, width, height).WithAttr("href", href)
+	e.Tag = "image"
+	return e
+}
+
+// ForeignObject creates a <foreignObject> element. Its children are HTML.
+func ForeignObject(x, y, width, height float64, children ...ui.UI) ui.Element {
+	e := Rect(x, y, width, height)
+	e.Tag = "foreignObject"
+	e.Children = children
+	return e
+}
@@ -18,10 +18,17 @@ type Element struct {
 	Attrs    map[string]string // Additional attributes
 	Children []UI              // Child nodes
 	Events   map[string]string // Event handler IDs
+	NS       string            // Namespace URI for SVG and MathML elements; empty for HTML
 }
 
 func (Element) isUI() {}
 
+// Namespaces for Element.NS.
+const (
+	NamespaceSVG    = "http://www.w3.org/2000/svg"
+	NamespaceMathML = "http://www.w3.org/1998/Math/MathML"
+)
+
 // Text represents a text node. Use T() to create text nodes.
 //
 //	ui.P(ui.T("Hello, World!"))
@@ -30,7 +37,8 @@ type Text struct{ Value string }
 func (Text) isUI() {}
 
 // Raw represents raw HTML that will be inserted without escaping.
-// Use with caution to avoid XSS vulnerabilities.
+// Use with caution to avoid XSS vulnerabilities; use SafeHTML for
+// content users can influence.
 //
 //	ui.Raw{HTML: "<strong>Bold</strong>"}
 type Raw struct{ HTML string }
@@ -0,0 +1,36 @@
+package ui
+
+import "github.com/Shravanthh/forge/ctx"
+
+// FileInput creates a file <input> element.
+// Combine with OnUpload to stream the selected files to the session.
+func FileInput() Element {
+	return Input().WithAttr("type", "file")
+}
+
+// OnUpload streams selected files over the socket and calls the handler
+// inside the session once each file has arrived.
+// Progress is available while uploading via UploadProgress.
+//
+//	ui.FileInput().WithID("avatar").OnUpload(c, func(c *ctx.Context, f ctx.UploadedFile) {
+//	    c.Set("avatar", f.Name)
+//	})
+func (e Element) OnUpload(c *ctx.Context, h ctx.UploadHandler) Element {
+	id := e.ID
+	if id == "" {
+		id = nextEventID(c)
+	}
+	handlerID := id + "_upload"
+	c.OnUpload(handlerID, h)
+	if e.Events == nil {
+		e.Events = make(map[string]string)
+	}
+	e.Events["upload"] = handlerID
+	return e
+}
+
+// UploadProgress returns the progress (0-100) of the upload on the
+// file input with the given ID.
+func UploadProgress(c *ctx.Context, id string) int {
+	return c.UploadProgress(id + "_upload")
+}